@@ -0,0 +1,426 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"net/http"
+
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/limits"
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/pricing"
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/provider"
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/vault"
+	"github.com/grassrootseconomics/eth-indexer/v2/pkg/inethi"
+	"github.com/grassrootseconomics/eth-indexer/v2/pkg/notify"
+	"github.com/grassrootseconomics/eth-indexer/v2/pkg/transport"
+	"github.com/knadh/koanf/v2"
+)
+
+func loadTiers(ko *koanf.Koanf) (*pricing.Tiers, error) {
+	var tiers []pricing.Tier
+
+	for _, t := range ko.Slices("tiers") {
+		minAmount, ok := new(big.Rat).SetString(t.String("min_amount"))
+		if !ok {
+			return nil, fmt.Errorf("tier %s: invalid min_amount %q", t.String("id"), t.String("min_amount"))
+		}
+
+		var maxAmount *big.Rat
+		if t.String("max_amount") != "" {
+			maxAmount, ok = new(big.Rat).SetString(t.String("max_amount"))
+			if !ok {
+				return nil, fmt.Errorf("tier %s: invalid max_amount %q", t.String("id"), t.String("max_amount"))
+			}
+		}
+
+		tiers = append(tiers, pricing.Tier{
+			ID:               t.String("id"),
+			Description:      t.String("description"),
+			ProfilePK:        t.Int("profile_pk"),
+			MinAmount:        minAmount,
+			MaxAmount:        maxAmount,
+			Enabled:          !t.Exists("enabled") || t.Bool("enabled"),
+			SubscriptionDays: t.Int("subscription_days"),
+			Profile:          t.String("profile"),
+		})
+	}
+
+	return pricing.NewTiers(tiers)
+}
+
+func loadAcceptedTokens(ko *koanf.Koanf) (*pricing.Tokens, error) {
+	var tokens []pricing.AcceptedToken
+
+	for _, t := range ko.Slices("accepted_tokens") {
+		multiplier := "1"
+		if t.String("price_multiplier") != "" {
+			multiplier = t.String("price_multiplier")
+		}
+
+		priceMultiplier, ok := new(big.Rat).SetString(multiplier)
+		if !ok {
+			return nil, fmt.Errorf("accepted token %s: invalid price_multiplier %q", t.String("address"), multiplier)
+		}
+
+		tokens = append(tokens, pricing.AcceptedToken{
+			Address:         t.String("address"),
+			PriceMultiplier: priceMultiplier,
+		})
+	}
+
+	return pricing.NewTokens(tokens)
+}
+
+func loadDecomposition(ko *koanf.Koanf, tiers *pricing.Tiers) (pricing.Decomposition, error) {
+	decomposition := pricing.Decomposition{
+		Policy:      ko.String("purchase.decomposition.policy"),
+		TierID:      ko.String("purchase.decomposition.tier"),
+		Quantity:    ko.Int("purchase.decomposition.quantity"),
+		MaxVouchers: ko.Int("purchase.decomposition.max_vouchers"),
+	}
+	if decomposition.Policy == "" {
+		decomposition.Policy = pricing.DecomposeSingle
+	}
+
+	return decomposition, decomposition.Validate(tiers)
+}
+
+func loadPricingGroups(ko *koanf.Koanf) (*pricing.Groups, error) {
+	var groups []pricing.PricingGroup
+
+	for _, g := range ko.Slices("pricing_groups") {
+		discount, ok := new(big.Rat).SetString(g.String("discount"))
+		if !ok {
+			return nil, fmt.Errorf("pricing group %s: invalid discount %q", g.String("id"), g.String("discount"))
+		}
+
+		groups = append(groups, pricing.PricingGroup{
+			ID:       g.String("id"),
+			Discount: discount,
+		})
+	}
+
+	return pricing.NewGroups(groups)
+}
+
+func loadAccess(ko *koanf.Koanf) vault.Access {
+	access := vault.Access{
+		Mode:     ko.String("access.mode"),
+		Unlisted: ko.String("access.unlisted"),
+	}
+	if access.Mode == "" {
+		access.Mode = vault.AccessOpen
+	}
+	if access.Unlisted == "" {
+		access.Unlisted = vault.UnlistedHold
+	}
+	return access
+}
+
+func loadPool(ko *koanf.Koanf) vault.Pool {
+	pool := vault.Pool{
+		Mode:     ko.String("pool.mode"),
+		LowStock: ko.Int("pool.low_stock"),
+	}
+	if pool.Mode == "" {
+		pool.Mode = vault.PoolDisabled
+	}
+	return pool
+}
+
+func loadLimits(ko *koanf.Koanf) (limits.Rules, error) {
+	rules := limits.Rules{
+		MaxSenderVouchers: ko.Int("limits.max_sender_vouchers"),
+		SenderWindow:      ko.Duration("limits.sender_window"),
+		MaxVouchers:       ko.Int("limits.max_vouchers"),
+		Window:            ko.Duration("limits.window"),
+	}
+
+	if ko.String("limits.max_sender_value") != "" {
+		maxValue, ok := new(big.Rat).SetString(ko.String("limits.max_sender_value"))
+		if !ok {
+			return rules, fmt.Errorf("invalid limits.max_sender_value %q", ko.String("limits.max_sender_value"))
+		}
+		rules.MaxSenderValue = maxValue
+	}
+
+	return rules, rules.Validate()
+}
+
+// loadTransport builds the transport shared by the iNethi and notify clients of every tenant.
+func loadTransport(ko *koanf.Koanf) *transport.Transport {
+	return transport.New(transport.Opts{
+		MaxRetries:       ko.Int("http.max_retries"),
+		AttemptTimeout:   ko.Duration("http.attempt_timeout"),
+		BaseBackoff:      ko.Duration("http.base_backoff"),
+		MaxBackoff:       ko.Duration("http.max_backoff"),
+		BreakerThreshold: ko.Int("http.breaker_threshold"),
+		BreakerCooldown:  ko.Duration("http.breaker_cooldown"),
+		Rate:             ko.Float64("http.rate"),
+		Burst:            ko.Int("http.burst"),
+	})
+}
+
+const defaultTenantID = "default"
+
+// loadVaults reads every tenant and its vaults. Without any [[tenants]], the top level configuration is used as
+// the single default tenant.
+func loadVaults(ko *koanf.Koanf) (*vault.Registry, error) {
+	tenants := ko.Slices("tenants")
+	if len(tenants) == 0 {
+		tenants = []*koanf.Koanf{ko}
+	}
+
+	httpTransport := loadTransport(ko)
+
+	var vaults []vault.Vault
+	for _, t := range tenants {
+		tenantVaults, err := loadTenant(t, httpTransport)
+		if err != nil {
+			return nil, err
+		}
+		vaults = append(vaults, tenantVaults...)
+	}
+
+	return vault.NewRegistry(vaults)
+}
+
+// loadTenant builds the vaults of a single tenant. Each tenant has its own iNethi and notify clients and
+// pricing. Vaults may override the tenant tier table, RadiusDesk site and notify settings. Without any
+// [[vaults]], chain.vault_address is used as the only vault.
+func loadTenant(ko *koanf.Koanf, httpTransport http.RoundTripper) ([]vault.Vault, error) {
+	tenantID := ko.String("id")
+	if tenantID == "" {
+		tenantID = defaultTenantID
+	}
+
+	tiers, err := loadTiers(ko)
+	if err != nil {
+		return nil, fmt.Errorf("tenant %s: %w", tenantID, err)
+	}
+
+	decomposition, err := loadDecomposition(ko, tiers)
+	if err != nil {
+		return nil, fmt.Errorf("tenant %s: %w", tenantID, err)
+	}
+
+	tokens, err := loadAcceptedTokens(ko)
+	if err != nil {
+		return nil, fmt.Errorf("tenant %s: %w", tenantID, err)
+	}
+
+	pricingGroups, err := loadPricingGroups(ko)
+	if err != nil {
+		return nil, fmt.Errorf("tenant %s: %w", tenantID, err)
+	}
+
+	purchaseLimits, err := loadLimits(ko)
+	if err != nil {
+		return nil, fmt.Errorf("tenant %s: %w", tenantID, err)
+	}
+	access := loadAccess(ko)
+	pool := loadPool(ko)
+
+	var (
+		iClient     *inethi.InethiClient
+		nClient     = notify.New(ko.MustString("notify.bearer_token"), ko.MustString("notify.endpoint"), httpTransport)
+		defaultSite = loadSite(ko.Cut("inethi"), inethi.Site{})
+	)
+
+	// The iNethi settings are only required when a vault issues its vouchers there.
+	inethiClient := func() *inethi.InethiClient {
+		if iClient == nil {
+			iClient = inethi.New(ko.MustString("inethi.api_key"), ko.MustString("inethi.endpoint"), httpTransport)
+		}
+		return iClient
+	}
+
+	// Providers other than iNethi are shared by all vaults of the tenant that do not configure their own.
+	var sharedProvider provider.VoucherProvider
+	tenantProvider := func(site inethi.Site) (provider.VoucherProvider, error) {
+		if providerType(ko.Cut("provider")) == providerInethi {
+			return provider.NewInethi(inethiClient(), site), nil
+		}
+		if sharedProvider == nil {
+			p, err := loadProvider(ko.Cut("provider"), site, inethiClient, httpTransport)
+			if err != nil {
+				return nil, err
+			}
+			sharedProvider = p
+		}
+		return sharedProvider, nil
+	}
+
+	var vaults []vault.Vault
+	for _, v := range ko.Slices("vaults") {
+		vaultTiers := tiers
+		if len(v.Slices("tiers")) > 0 {
+			vaultTiers, err = loadTiers(v)
+			if err != nil {
+				return nil, fmt.Errorf("tenant %s: vault %s: %w", tenantID, v.String("address"), err)
+			}
+		}
+
+		notifyClient := nClient
+		if v.String("notify.endpoint") != "" {
+			notifyClient = notify.New(v.String("notify.bearer_token"), v.String("notify.endpoint"), httpTransport)
+		}
+
+		vaultPool := pool
+		if v.String("pool_mode") != "" {
+			vaultPool.Mode = v.String("pool_mode")
+		}
+
+		site := loadSite(v, defaultSite)
+		var vaultProvider provider.VoucherProvider
+		if vaultPool.Mode == vault.PoolOnly {
+			vaultProvider = provider.None{}
+		} else if v.Exists("provider") {
+			vaultProvider, err = loadProvider(v.Cut("provider"), site, inethiClient, httpTransport)
+		} else {
+			vaultProvider, err = tenantProvider(site)
+		}
+		if err == nil {
+			err = checkProfileNames(vaultTiers, vaultProvider)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s: vault %s: %w", tenantID, v.String("address"), err)
+		}
+
+		vaults = append(vaults, vault.Vault{
+			TenantID:      tenantID,
+			Address:       v.String("address"),
+			Tiers:         vaultTiers,
+			Decomposition: decomposition,
+			Tokens:        tokens,
+			PricingGroups: pricingGroups,
+			Access:        access,
+			Limits:        purchaseLimits,
+			Provider:      vaultProvider,
+			NotifyClient:  notifyClient,
+			Pool:          vaultPool,
+		})
+	}
+
+	if len(vaults) == 0 {
+		if ko.String("chain.vault_address") == "" {
+			return nil, fmt.Errorf("tenant %s: no vaults configured", tenantID)
+		}
+
+		var vaultProvider provider.VoucherProvider = provider.None{}
+		if pool.Mode != vault.PoolOnly {
+			vaultProvider, err = tenantProvider(defaultSite)
+			if err != nil {
+				return nil, fmt.Errorf("tenant %s: %w", tenantID, err)
+			}
+		}
+		if err := checkProfileNames(tiers, vaultProvider); err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", tenantID, err)
+		}
+
+		vaults = append(vaults, vault.Vault{
+			TenantID:      tenantID,
+			Address:       ko.String("chain.vault_address"),
+			Tiers:         tiers,
+			Decomposition: decomposition,
+			Tokens:        tokens,
+			PricingGroups: pricingGroups,
+			Access:        access,
+			Limits:        purchaseLimits,
+			Provider:      vaultProvider,
+			NotifyClient:  nClient,
+			Pool:          pool,
+		})
+	}
+
+	return vaults, nil
+}
+
+// checkProfileNames rejects tiers that only name their RadiusDesk profile on a vault whose provider is not iNethi.
+// Profile names are only resolved from iNethi, such a tier would be issued and pooled without a profile pk.
+func checkProfileNames(tiers *pricing.Tiers, vaultProvider provider.VoucherProvider) error {
+	if _, ok := vaultProvider.(*provider.Inethi); ok {
+		return nil
+	}
+
+	for _, tier := range tiers.All() {
+		if tier.Profile != "" && tier.ProfilePK == 0 {
+			return fmt.Errorf("tier %s names profile %q without a profile_pk, profile names are only resolved with the inethi provider", tier.ID, tier.Profile)
+		}
+	}
+	return nil
+}
+
+func loadSite(ko *koanf.Koanf, fallback inethi.Site) inethi.Site {
+	site := fallback
+	if ko.Exists("radiusdesk_instance_pk") {
+		site.InstancePK = ko.Int("radiusdesk_instance_pk")
+	}
+	if ko.Exists("radiusdesk_cloud_pk") {
+		site.CloudPK = ko.Int("radiusdesk_cloud_pk")
+	}
+	if ko.Exists("radiusdesk_realm_pk") {
+		site.RealmPK = ko.Int("radiusdesk_realm_pk")
+	}
+	return site
+}
+
+const (
+	providerInethi = "inethi"
+	providerHTTP   = "http"
+	providerRadius = "radius"
+)
+
+func providerType(ko *koanf.Koanf) string {
+	if ko.String("type") == "" {
+		return providerInethi
+	}
+	return ko.String("type")
+}
+
+// loadProvider builds the voucher provider of a [provider] section. iNethi providers issue on site with the
+// tenant's iNethi client, HTTP providers send through the shared transport.
+func loadProvider(ko *koanf.Koanf, site inethi.Site, inethiClient func() *inethi.InethiClient, httpTransport http.RoundTripper) (provider.VoucherProvider, error) {
+	switch providerType(ko) {
+	case providerInethi:
+		return provider.NewInethi(inethiClient(), site), nil
+	case providerHTTP:
+		if ko.String("endpoint") == "" || ko.String("issue_path") == "" {
+			return nil, fmt.Errorf("http provider needs an endpoint and issue_path")
+		}
+		return provider.NewHTTP(provider.HTTPOpts{
+			Endpoint:   ko.String("endpoint"),
+			IssuePath:  ko.String("issue_path"),
+			RevokePath: ko.String("revoke_path"),
+			StatusPath: ko.String("status_path"),
+			Headers:    ko.StringMap("headers"),
+			CodeField:  ko.String("code_field"),
+			StateField: ko.String("state_field"),
+			Transport:  httpTransport,
+		}), nil
+	case providerRadius:
+		if ko.String("dsn") == "" {
+			return nil, fmt.Errorf("radius provider needs a dsn")
+		}
+		return provider.NewRadius(provider.RadiusOpts{
+			DSN:         ko.String("dsn"),
+			QueriesPath: radiusQueriesFlag,
+			Groups:      ko.StringMap("groups"),
+		})
+	default:
+		return nil, fmt.Errorf("unknown provider type %q", ko.String("type"))
+	}
+}
+
+// closeProviders releases the connections held by the vaults' providers, e.g. the FreeRADIUS database pools. A
+// provider shared by several vaults is closed once.
+func closeProviders(vaults *vault.Registry) {
+	closed := make(map[provider.VoucherProvider]bool)
+	for _, v := range vaults.All() {
+		p, ok := v.Provider.(interface{ Close() })
+		if !ok || closed[v.Provider] {
+			continue
+		}
+		p.Close()
+		closed[v.Provider] = true
+	}
+}
@@ -15,11 +15,13 @@ import (
 	"github.com/grassrootseconomics/eth-indexer/v2/internal/api"
 	"github.com/grassrootseconomics/eth-indexer/v2/internal/cache"
 	"github.com/grassrootseconomics/eth-indexer/v2/internal/handler"
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/outbox"
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/profiles"
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/refund"
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/reminder"
 	"github.com/grassrootseconomics/eth-indexer/v2/internal/store"
 	"github.com/grassrootseconomics/eth-indexer/v2/internal/sub"
 	"github.com/grassrootseconomics/eth-indexer/v2/internal/util"
-	"github.com/grassrootseconomics/eth-indexer/v2/pkg/inethi"
-	"github.com/grassrootseconomics/eth-indexer/v2/pkg/notify"
 	"github.com/grassrootseconomics/ethutils"
 	"github.com/knadh/koanf/v2"
 )
@@ -32,6 +34,7 @@ var (
 	confFlag             string
 	migrationsFolderFlag string
 	queriesFlag          string
+	radiusQueriesFlag    string
 
 	lo *slog.Logger
 	ko *koanf.Koanf
@@ -41,6 +44,7 @@ func init() {
 	flag.StringVar(&confFlag, "config", "config.toml", "Config file location")
 	flag.StringVar(&migrationsFolderFlag, "migrations", "migrations/", "Migrations folder location")
 	flag.StringVar(&queriesFlag, "queries", "queries.sql", "Queries file location")
+	flag.StringVar(&radiusQueriesFlag, "radius-queries", "radius.sql", "FreeRADIUS provider queries file location")
 	flag.Parse()
 
 	lo = util.InitLogger()
@@ -50,7 +54,9 @@ func init() {
 }
 
 func main() {
-	var wg sync.WaitGroup
+	// workers holds the background workers that use the store and the providers, they are closed only after all
+	// of them have returned.
+	var wg, workers sync.WaitGroup
 	ctx, stop := notifyShutdown()
 
 	store, err := store.NewPgStore(store.PgOpts{
@@ -64,9 +70,39 @@ func main() {
 		os.Exit(1)
 	}
 
-	iClient := inethi.New(ko.MustString("inethi.api_key"), ko.MustString("inethi.endpoint"))
+	vaults, err := loadVaults(ko)
+	if err != nil {
+		lo.Error("could not load tenants and vaults", "error", err)
+		os.Exit(1)
+	}
+	for _, v := range vaults.All() {
+		if v.Tokens.Size() == 0 {
+			lo.Warn("no accepted payment tokens configured, all vault transfers will be recorded as unaccepted payments", "tenant", v.TenantID, "vault", v.Address)
+		}
+	}
 
-	nClient := notify.New(ko.MustString("notify.bearer_token"), ko.MustString("notify.endpoint"))
+	profileWorker := profiles.New(profiles.WorkerOpts{
+		Vaults:       vaults,
+		Logg:         lo,
+		SyncInterval: ko.Duration("profiles.sync_interval"),
+	})
+	if err := profileWorker.Sync(ctx); errors.Is(err, profiles.ErrMissingProfile) {
+		lo.Error("tiers point at missing RadiusDesk profiles", "error", err)
+		os.Exit(1)
+	} else if errors.Is(err, profiles.ErrUnresolvedProfile) {
+		lo.Error("could not sync RadiusDesk profiles for tiers that only name their profile", "error", err)
+		os.Exit(1)
+	} else if err != nil {
+		lo.Warn("could not sync RadiusDesk profiles, using the configured profile pks", "error", err)
+	}
+
+	// Pool codes are keyed by profile pk, so the import runs once the tiers that only name their profile are resolved.
+	if flag.Arg(0) == "import-pool" {
+		code := importPool(ctx, store, vaults, flag.Args()[1:])
+		store.Close()
+		closeProviders(vaults)
+		os.Exit(code)
+	}
 
 	cache := cache.New()
 
@@ -75,17 +111,69 @@ func main() {
 		ko.MustInt64("chain.chainid"),
 	)
 
+	if ko.Duration("orders.ttl") > 0 && ko.Int64("orders.max_offset") <= 0 {
+		lo.Error("orders need a positive orders.max_offset")
+		os.Exit(1)
+	}
+
 	handlerContainer := handler.NewHandler(handler.HandlerOpts{
+		Store:                store,
+		Cache:                cache,
+		Vaults:               vaults,
+		PartialPaymentWindow: ko.Duration("purchase.partial_payment_window"),
+		Refunds:              ko.Bool("refunds.enabled"),
+		OrderTTL:             ko.Duration("orders.ttl"),
+		OrderMaxOffset:       ko.Int64("orders.max_offset"),
+		OrderMaxOpen:         ko.Int("orders.max_open"),
+		ChainProvider:        chainProvider,
+		Logg:                 lo,
+	})
+
+	router := bootstrapRouter(handlerContainer)
+
+	outboxWorker := outbox.New(outbox.WorkerOpts{
+		Store:           store,
+		Issue:           handlerContainer.IssueVoucher,
+		ChainProvider:   chainProvider,
+		Confirmations:   uint64(ko.Int64("chain.confirmations")),
+		Refund:          ko.Bool("refunds.enabled"),
+		Logg:            lo,
+		PollInterval:    ko.MustDuration("outbox.poll_interval"),
+		BatchSize:       ko.MustInt("outbox.batch_size"),
+		MaxAttempts:     ko.MustInt("outbox.max_attempts"),
+		BaseBackoff:     ko.MustDuration("outbox.base_backoff"),
+		MaxBackoff:      ko.MustDuration("outbox.max_backoff"),
+		ProcessingLease: ko.MustDuration("outbox.processing_lease"),
+	})
+
+	reminderWorker := reminder.New(reminder.WorkerOpts{
 		Store:         store,
-		Cache:         cache,
-		InethiClient:  iClient,
-		NotifyClient:  nClient,
-		VaultAddress:  ko.MustString("chain.vault_address"),
-		ChainProvider: chainProvider,
+		Vaults:        vaults,
 		Logg:          lo,
+		Lead:          time.Duration(ko.MustInt("subscriptions.reminder_days")) * 24 * time.Hour,
+		CheckInterval: ko.MustDuration("subscriptions.check_interval"),
 	})
 
-	router := bootstrapRouter(handlerContainer)
+	var refundWorker *refund.Worker
+	if ko.Bool("refunds.enabled") {
+		signer := refund.NewKeystoreSigner(ko.MustString("refunds.keystore_dir"), ko.String("refunds.passphrase"), ko.MustInt64("chain.chainid"))
+		for _, v := range vaults.All() {
+			if !signer.Has(ethutils.HexToAddress(v.Address)) {
+				lo.Error("refunds enabled but the vault key is missing from the keystore", "tenant", v.TenantID, "vault", v.Address)
+				os.Exit(1)
+			}
+		}
+
+		refundWorker = refund.New(refund.WorkerOpts{
+			Store:        store,
+			Backend:      refund.NewProviderBackend(chainProvider),
+			Signer:       signer,
+			Logg:         lo,
+			PollInterval: ko.MustDuration("refunds.poll_interval"),
+			BatchSize:    ko.MustInt("refunds.batch_size"),
+			MaxAttempts:  ko.MustInt("refunds.max_attempts"),
+		})
+	}
 
 	jetStreamSub, err := sub.NewJetStreamSub(sub.JetStreamOpts{
 		Logg:        lo,
@@ -99,8 +187,17 @@ func main() {
 	}
 
 	apiServer := &http.Server{
-		Addr:    ko.MustString("api.address"),
-		Handler: api.New(),
+		Addr: ko.MustString("api.address"),
+		Handler: api.New(api.APIOpts{
+			Store:      store,
+			Handler:    handlerContainer,
+			AdminToken: ko.String("api.admin_token"),
+			Logg:       lo,
+			ChainID:    ko.MustInt64("chain.chainid"),
+			Orders:     ko.Duration("orders.ttl") > 0,
+			OrderRate:  ko.Float64("orders.rate"),
+			OrderBurst: ko.Int("orders.burst"),
+		}),
 	}
 
 	wg.Add(1)
@@ -109,6 +206,34 @@ func main() {
 		jetStreamSub.Process()
 	}()
 
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		outboxWorker.Run(ctx)
+	}()
+
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		reminderWorker.Run(ctx)
+	}()
+
+	if ko.Duration("profiles.sync_interval") > 0 {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			profileWorker.Run(ctx)
+		}()
+	}
+
+	if refundWorker != nil {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			refundWorker.Run(ctx)
+		}()
+	}
+
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
@@ -127,8 +252,10 @@ func main() {
 	go func() {
 		defer wg.Done()
 		jetStreamSub.Close()
-		store.Close()
 		apiServer.Shutdown(shutdownCtx)
+		workers.Wait()
+		store.Close()
+		closeProviders(vaults)
 	}()
 
 	go func() {
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/handler"
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/store"
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/vault"
+)
+
+// importPool runs the import-pool subcommand: import-pool <vault address> <csv file>. It returns the exit code.
+func importPool(ctx context.Context, store store.Store, vaults *vault.Registry, args []string) int {
+	if len(args) != 2 {
+		lo.Error("usage: import-pool <vault address> <csv file>")
+		return 2
+	}
+
+	v, ok := vaults.Get(args[0])
+	if !ok {
+		lo.Error("not a configured vault", "vault", args[0])
+		return 1
+	}
+
+	f, err := os.Open(args[1])
+	if err != nil {
+		lo.Error("could not open voucher pool file", "error", err)
+		return 1
+	}
+	defer f.Close()
+
+	h := handler.NewHandler(handler.HandlerOpts{
+		Store:  store,
+		Vaults: vaults,
+		Logg:   lo,
+	})
+	imported, err := h.ImportPool(ctx, v.TenantID, v.Address, f)
+	if err != nil {
+		lo.Error("could not import voucher pool", "error", err, "vault", v.Address)
+		return 1
+	}
+
+	lo.Info("voucher pool imported", "vault", v.Address, "imported", imported)
+	return 0
+}
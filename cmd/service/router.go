@@ -12,7 +12,6 @@ func bootstrapRouter(handlerContainer *handler.Handler) *router.Router {
 		"TRACKER.TOKEN_TRANSFER",
 		handlerContainer.IndexTransfer,
 		handlerContainer.AddToken,
-		handlerContainer.GenerateVoucher,
 	)
 	// router.RegisterRoute(
 	// 	"TRACKER.TOKEN_MINT",
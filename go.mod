@@ -19,7 +19,9 @@ require (
 	github.com/lmittmann/w3 v0.17.1
 	github.com/nats-io/nats.go v1.37.0
 	github.com/puzpuzpuz/xsync/v3 v3.4.0
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
 	github.com/sourcegraph/conc v0.3.0
+	golang.org/x/time v0.7.0
 )
 
 require (
@@ -72,6 +74,5 @@ require (
 	golang.org/x/sync v0.8.0 // indirect
 	golang.org/x/sys v0.25.0 // indirect
 	golang.org/x/text v0.18.0 // indirect
-	golang.org/x/time v0.7.0 // indirect
 	rsc.io/tmplfunc v0.0.3 // indirect
 )
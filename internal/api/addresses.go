@@ -0,0 +1,116 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/go-chi/chi/v5"
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/store"
+	"github.com/grassrootseconomics/ethutils"
+)
+
+type addressRequest struct {
+	Allowed      bool   `json:"allowed"`
+	Blocked      bool   `json:"blocked"`
+	Member       bool   `json:"member"`
+	PricingGroup string `json:"pricingGroup"`
+	Note         string `json:"note"`
+}
+
+func (a *API) listAddressesHandler(w http.ResponseWriter, r *http.Request) {
+	entries, err := a.store.ListAddresses(r.Context(), tenantParam(r))
+	if err != nil {
+		a.logg.Error("api: failed to list addresses", "error", err)
+		a.writeError(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	a.writeJSON(w, http.StatusOK, entries)
+}
+
+func (a *API) getAddressHandler(w http.ResponseWriter, r *http.Request) {
+	address, ok := a.addressParam(w, r)
+	if !ok {
+		return
+	}
+
+	entry, err := a.store.GetAddress(r.Context(), tenantParam(r), address)
+	if errors.Is(err, store.ErrNotFound) {
+		a.writeError(w, http.StatusNotFound, "address not registered")
+		return
+	}
+	if err != nil {
+		a.logg.Error("api: failed to get address", "error", err, "address", address)
+		a.writeError(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	a.writeJSON(w, http.StatusOK, entry)
+}
+
+func (a *API) putAddressHandler(w http.ResponseWriter, r *http.Request) {
+	address, ok := a.addressParam(w, r)
+	if !ok {
+		return
+	}
+
+	var req addressRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		a.writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Blocked && (req.Allowed || req.Member) {
+		a.writeError(w, http.StatusBadRequest, "a blocked address can not be allowed or a member")
+		return
+	}
+
+	entry := &store.AddressEntry{
+		TenantID:     tenantParam(r),
+		Address:      address,
+		Allowed:      req.Allowed,
+		Blocked:      req.Blocked,
+		Member:       req.Member,
+		PricingGroup: req.PricingGroup,
+		Note:         req.Note,
+	}
+	if err := a.store.UpsertAddress(r.Context(), entry); err != nil {
+		a.logg.Error("api: failed to save address", "error", err, "address", address)
+		a.writeError(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+	a.logg.Info("api: address registry updated", "tenant", entry.TenantID, "address", address, "allowed", entry.Allowed, "blocked", entry.Blocked, "member", entry.Member, "group", entry.PricingGroup)
+
+	a.writeJSON(w, http.StatusOK, entry)
+}
+
+func (a *API) deleteAddressHandler(w http.ResponseWriter, r *http.Request) {
+	address, ok := a.addressParam(w, r)
+	if !ok {
+		return
+	}
+
+	err := a.store.DeleteAddress(r.Context(), tenantParam(r), address)
+	if errors.Is(err, store.ErrNotFound) {
+		a.writeError(w, http.StatusNotFound, "address not registered")
+		return
+	}
+	if err != nil {
+		a.logg.Error("api: failed to delete address", "error", err, "address", address)
+		a.writeError(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// addressParam returns the checksummed address of the request path, writing an error response when it is invalid.
+func (a *API) addressParam(w http.ResponseWriter, r *http.Request) (string, bool) {
+	address := chi.URLParam(r, "address")
+	if !common.IsHexAddress(address) {
+		a.writeError(w, http.StatusBadRequest, "invalid address")
+		return "", false
+	}
+	return ethutils.ChecksumAddress(address), true
+}
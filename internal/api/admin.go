@@ -0,0 +1,28 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// requireAdmin only lets requests carrying the admin bearer token through.
+func (a *API) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(a.adminToken)) != 1 {
+			a.writeError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// tenantParam returns the tenant named by the request, or the default tenant.
+func tenantParam(r *http.Request) string {
+	if tenantID := r.URL.Query().Get("tenant"); tenantID != "" {
+		return tenantID
+	}
+	return defaultTenantID
+}
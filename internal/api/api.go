@@ -1,16 +1,102 @@
 package api
 
 import (
+	"encoding/json"
+	"log/slog"
 	"net/http"
 
 	"github.com/VictoriaMetrics/metrics"
 	"github.com/go-chi/chi/v5"
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/handler"
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/store"
 )
 
-func New() *chi.Mux {
+type (
+	APIOpts struct {
+		Store   store.Store
+		Handler *handler.Handler
+		// AdminToken is the bearer token of the /admin endpoints, they are disabled when it is empty.
+		AdminToken string
+		Logg       *slog.Logger
+		// ChainID is used in payment URIs.
+		ChainID int64
+		// Orders enables the /orders endpoints.
+		Orders bool
+		// OrderRate is the number of orders per second a client may create, with bursts of up to OrderBurst.
+		// 0 leaves order creation unlimited.
+		OrderRate  float64
+		OrderBurst int
+	}
+
+	API struct {
+		store      store.Store
+		handler    *handler.Handler
+		adminToken string
+		logg       *slog.Logger
+		chainID    int64
+	}
+
+	errResponse struct {
+		Ok          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+)
+
+func New(o APIOpts) *chi.Mux {
+	a := &API{
+		store:      o.Store,
+		handler:    o.Handler,
+		adminToken: o.AdminToken,
+		logg:       o.Logg,
+		chainID:    o.ChainID,
+	}
+
 	r := chi.NewRouter()
 
 	r.Get("/metrics", metricsHandler())
+	r.Get("/credits/{address}", a.creditsHandler)
+	r.Get("/catalog", a.catalogHandler)
+	r.Get("/tiers/{id}/payment", a.tierPaymentHandler)
+
+	if o.Orders {
+		r.Group(func(r chi.Router) {
+			if o.OrderRate > 0 {
+				r.Use(a.rateLimit(newClientLimiter(o.OrderRate, o.OrderBurst)))
+			}
+			r.Post("/orders", a.createOrderHandler)
+		})
+		r.Get("/orders/{id}", a.getOrderHandler)
+		r.Get("/orders/{id}/payment", a.orderPaymentHandler)
+	}
+
+	if a.adminToken != "" {
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(a.requireAdmin)
+
+			r.Get("/addresses", a.listAddressesHandler)
+			r.Get("/addresses/{address}", a.getAddressHandler)
+			r.Put("/addresses/{address}", a.putAddressHandler)
+			r.Delete("/addresses/{address}", a.deleteAddressHandler)
+
+			r.Get("/reviews", a.listReviewsHandler)
+			r.Get("/reviews/{id}", a.getReviewHandler)
+			r.Post("/reviews/{id}/resolve", a.resolveReviewHandler)
+
+			r.Get("/unmatched", a.listUnmatchedHandler)
+			r.Get("/unmatched/{id}", a.getUnmatchedHandler)
+			r.Post("/unmatched/{id}/resolve", a.resolveUnmatchedHandler)
+
+			r.Get("/vaults/{address}/pool", a.poolStockHandler)
+			r.Post("/vaults/{address}/pool", a.importPoolHandler)
+
+			r.Get("/profiles", a.profileMappingsHandler)
+			r.Get("/vaults/{address}/profiles", a.profilesHandler)
+			r.Get("/vaults/{address}/vouchers", a.listVouchersHandler)
+			r.Get("/vaults/{address}/vouchers/{code}", a.voucherStatusHandler)
+			r.Post("/vaults/{address}/vouchers/{code}/revoke", a.revokeVoucherHandler)
+			r.Delete("/vaults/{address}/vouchers/{code}", a.deleteVoucherHandler)
+		})
+	}
 
 	return r
 }
@@ -20,3 +106,18 @@ func metricsHandler() http.HandlerFunc {
 		metrics.WritePrometheus(w, true)
 	}
 }
+
+func (a *API) writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		a.logg.Error("api: failed to write response", "error", err)
+	}
+}
+
+func (a *API) writeError(w http.ResponseWriter, status int, description string) {
+	a.writeJSON(w, status, errResponse{
+		Ok:          false,
+		Description: description,
+	})
+}
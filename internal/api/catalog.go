@@ -0,0 +1,41 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+)
+
+// catalogHandler returns the tenant's catalog with a content ETag, answering 304 when the client's copy is current.
+func (a *API) catalogHandler(w http.ResponseWriter, r *http.Request) {
+	catalog, err := a.handler.Catalog(r.Context(), tenantParam(r))
+	if err != nil {
+		a.logg.Error("api: failed to build catalog", "error", err)
+		a.writeError(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	body, err := json.Marshal(catalog)
+	if err != nil {
+		a.logg.Error("api: failed to encode catalog", "error", err)
+		a.writeError(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(body); err != nil {
+		a.logg.Error("api: failed to write response", "error", err)
+	}
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/store"
+)
+
+// defaultTenantID is the tenant used when a request does not name one.
+const defaultTenantID = "default"
+
+type creditsResponse struct {
+	TenantID       string         `json:"tenantId"`
+	Address        string         `json:"address"`
+	Balance        string         `json:"balance"`
+	PartialBalance string         `json:"partialBalance"`
+	Entries        []store.Credit `json:"entries"`
+}
+
+func (a *API) creditsHandler(w http.ResponseWriter, r *http.Request) {
+	address, ok := a.addressParam(w, r)
+	if !ok {
+		return
+	}
+	tenantID := tenantParam(r)
+
+	balance, err := a.store.GetCreditBalance(r.Context(), tenantID, address)
+	if err != nil {
+		a.logg.Error("api: failed to get credit balance", "error", err, "address", address)
+		a.writeError(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	partialBalance, err := a.store.GetPartialBalance(r.Context(), tenantID, address, time.Now())
+	if err != nil {
+		a.logg.Error("api: failed to get partial payment balance", "error", err, "address", address)
+		a.writeError(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	entries, err := a.store.GetCredits(r.Context(), tenantID, address)
+	if err != nil {
+		a.logg.Error("api: failed to get credits", "error", err, "address", address)
+		a.writeError(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	a.writeJSON(w, http.StatusOK, creditsResponse{
+		TenantID:       tenantID,
+		Address:        address,
+		Balance:        balance,
+		PartialBalance: partialBalance,
+		Entries:        entries,
+	})
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/handler"
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/payment"
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/store"
+)
+
+type (
+	orderRequest struct {
+		Tenant string `json:"tenant"`
+		Vault  string `json:"vault"`
+		Tier   string `json:"tier"`
+		Token  string `json:"token"`
+	}
+
+	orderResponse struct {
+		store.Order
+		PaymentURI string `json:"paymentUri"`
+	}
+)
+
+func (a *API) createOrderHandler(w http.ResponseWriter, r *http.Request) {
+	var req orderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		a.writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Tenant == "" {
+		req.Tenant = defaultTenantID
+	}
+
+	order, err := a.handler.CreateOrder(r.Context(), handler.PurchaseRequest{
+		TenantID:     req.Tenant,
+		VaultAddress: req.Vault,
+		TierID:       req.Tier,
+		TokenAddress: req.Token,
+	})
+	if errors.Is(err, handler.ErrInvalidPurchase) {
+		a.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if errors.Is(err, handler.ErrOrdersExhausted) {
+		a.logg.Warn("api: no order available", "error", err, "tier", req.Tier)
+		a.writeError(w, http.StatusServiceUnavailable, "no order available, try again later")
+		return
+	}
+	if err != nil {
+		a.logg.Error("api: failed to create order", "error", err, "tier", req.Tier)
+		a.writeError(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	a.writeJSON(w, http.StatusCreated, a.orderResponse(order))
+}
+
+func (a *API) getOrderHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	order, err := a.store.GetOrder(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		a.writeError(w, http.StatusNotFound, "order not found")
+		return
+	}
+	if err != nil {
+		a.logg.Error("api: failed to get order", "error", err, "order", id)
+		a.writeError(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	a.writeJSON(w, http.StatusOK, a.orderResponse(order))
+}
+
+func (a *API) orderResponse(order store.Order) orderResponse {
+	value, _ := new(big.Int).SetString(order.Value, 10)
+	return orderResponse{
+		Order:      order,
+		PaymentURI: payment.TransferURI(a.chainID, order.ContractAddress, order.VaultAddress, value),
+	}
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"errors"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/handler"
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/payment"
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/store"
+)
+
+// qrSize is the width and height of payment QR codes in pixels.
+const qrSize = 256
+
+type paymentResponse struct {
+	OrderID         string     `json:"orderId,omitempty"`
+	Tier            string     `json:"tier"`
+	TierDescription string     `json:"tierDescription"`
+	VaultAddress    string     `json:"vaultAddress"`
+	TokenAddress    string     `json:"tokenAddress"`
+	TokenSymbol     string     `json:"tokenSymbol"`
+	TokenAmount     string     `json:"tokenAmount"`
+	Value           string     `json:"value"`
+	ChainID         int64      `json:"chainId"`
+	ExpiresAt       *time.Time `json:"expiresAt,omitempty"`
+	PaymentURI      string     `json:"paymentUri"`
+	// QRCode is the PNG QR code of PaymentURI, base64 encoded.
+	QRCode []byte `json:"qrCode"`
+}
+
+// tierPaymentHandler returns the payment instructions for buying a tier by amount. With ?format=png only the QR
+// code is returned.
+func (a *API) tierPaymentHandler(w http.ResponseWriter, r *http.Request) {
+	p, err := a.handler.TierPayment(r.Context(), handler.PurchaseRequest{
+		TenantID:     tenantParam(r),
+		VaultAddress: r.URL.Query().Get("vault"),
+		TierID:       chi.URLParam(r, "id"),
+		TokenAddress: r.URL.Query().Get("token"),
+	})
+	if errors.Is(err, handler.ErrInvalidPurchase) {
+		a.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		a.logg.Error("api: failed to build tier payment", "error", err, "tier", chi.URLParam(r, "id"))
+		a.writeError(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	a.writePayment(w, r, paymentResponse{
+		Tier:            p.Tier.ID,
+		TierDescription: p.Tier.Description,
+		VaultAddress:    p.VaultAddress,
+		TokenAddress:    p.TokenAddress,
+		TokenSymbol:     p.TokenSymbol,
+		TokenAmount:     p.TokenAmount,
+		Value:           p.Value.String(),
+	})
+}
+
+// orderPaymentHandler returns the payment instructions of an order. With ?format=png only the QR code is returned.
+func (a *API) orderPaymentHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	order, err := a.store.GetOrder(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		a.writeError(w, http.StatusNotFound, "order not found")
+		return
+	}
+	if err != nil {
+		a.logg.Error("api: failed to get order", "error", err, "order", id)
+		a.writeError(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+	if order.Status != store.OrderStatusOpen {
+		a.writeError(w, http.StatusConflict, "order is "+order.Status)
+		return
+	}
+
+	a.writePayment(w, r, paymentResponse{
+		OrderID:         order.ID,
+		Tier:            order.Tier,
+		TierDescription: order.TierDescription,
+		VaultAddress:    order.VaultAddress,
+		TokenAddress:    order.ContractAddress,
+		TokenSymbol:     order.TokenSymbol,
+		TokenAmount:     order.TokenAmount,
+		Value:           order.Value,
+		ExpiresAt:       &order.ExpiresAt,
+	})
+}
+
+func (a *API) writePayment(w http.ResponseWriter, r *http.Request, resp paymentResponse) {
+	value, _ := new(big.Int).SetString(resp.Value, 10)
+	resp.ChainID = a.chainID
+	resp.PaymentURI = payment.TransferURI(a.chainID, resp.TokenAddress, resp.VaultAddress, value)
+
+	qrCode, err := payment.QRCode(resp.PaymentURI, qrSize)
+	if err != nil {
+		a.logg.Error("api: failed to render payment qr code", "error", err, "uri", resp.PaymentURI)
+		a.writeError(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	if r.URL.Query().Get("format") == "png" {
+		w.Header().Set("Content-Type", "image/png")
+		w.WriteHeader(http.StatusOK)
+		if _, err := w.Write(qrCode); err != nil {
+			a.logg.Error("api: failed to write response", "error", err)
+		}
+		return
+	}
+
+	resp.QRCode = qrCode
+	a.writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/handler"
+)
+
+// maxPoolImportSize bounds the CSV body of a pool import.
+const maxPoolImportSize = 8 << 20
+
+type importPoolResponse struct {
+	Imported int `json:"imported"`
+}
+
+func (a *API) poolStockHandler(w http.ResponseWriter, r *http.Request) {
+	address, ok := a.addressParam(w, r)
+	if !ok {
+		return
+	}
+
+	stock, err := a.handler.PoolStock(r.Context(), tenantParam(r), address)
+	if errors.Is(err, handler.ErrUnknownVault) {
+		a.writeError(w, http.StatusNotFound, "vault not found")
+		return
+	}
+	if err != nil {
+		a.logg.Error("api: failed to get voucher pool stock", "error", err, "vault", address)
+		a.writeError(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	a.writeJSON(w, http.StatusOK, stock)
+}
+
+// importPoolHandler takes a CSV body of profile pk and code records.
+func (a *API) importPoolHandler(w http.ResponseWriter, r *http.Request) {
+	address, ok := a.addressParam(w, r)
+	if !ok {
+		return
+	}
+
+	imported, err := a.handler.ImportPool(r.Context(), tenantParam(r), address, http.MaxBytesReader(w, r.Body, maxPoolImportSize))
+	if errors.Is(err, handler.ErrUnknownVault) {
+		a.writeError(w, http.StatusNotFound, "vault not found")
+		return
+	}
+	if errors.Is(err, handler.ErrInvalidPool) {
+		a.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		a.logg.Error("api: failed to import voucher pool", "error", err, "vault", address)
+		a.writeError(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	a.writeJSON(w, http.StatusOK, importPoolResponse{Imported: imported})
+}
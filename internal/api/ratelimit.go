@@ -0,0 +1,83 @@
+package api
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// clientIdleTTL is how long the limiter of a client that sent no request is kept.
+const clientIdleTTL = time.Minute * 10
+
+type (
+	// clientLimiter rate limits requests per client IP address.
+	clientLimiter struct {
+		rate  rate.Limit
+		burst int
+
+		mu        sync.Mutex
+		clients   map[string]*client
+		lastSweep time.Time
+	}
+
+	client struct {
+		limiter  *rate.Limiter
+		lastSeen time.Time
+	}
+)
+
+func newClientLimiter(r float64, burst int) *clientLimiter {
+	return &clientLimiter{
+		rate:      rate.Limit(r),
+		burst:     max(burst, 1),
+		clients:   make(map[string]*client),
+		lastSweep: time.Now(),
+	}
+}
+
+func (l *clientLimiter) allow(ip string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastSweep) > clientIdleTTL {
+		for key, c := range l.clients {
+			if now.Sub(c.lastSeen) > clientIdleTTL {
+				delete(l.clients, key)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	c, ok := l.clients[ip]
+	if !ok {
+		c = &client{limiter: rate.NewLimiter(l.rate, l.burst)}
+		l.clients[ip] = c
+	}
+	c.lastSeen = now
+
+	return c.limiter.Allow()
+}
+
+// rateLimit rejects requests of a client over its rate with 429. The client is identified by the connection's
+// remote address, a proxy in front of the API has to be configured to pass the client address through.
+func (a *API) rateLimit(l *clientLimiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				ip = r.RemoteAddr
+			}
+
+			if !l.allow(ip) {
+				w.Header().Set("Retry-After", "1")
+				a.writeError(w, http.StatusTooManyRequests, "too many requests")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
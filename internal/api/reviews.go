@@ -0,0 +1,81 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/handler"
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/store"
+)
+
+type reviewRequest struct {
+	Action string `json:"action"`
+	Note   string `json:"note"`
+}
+
+func (a *API) listReviewsHandler(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = store.ReviewStatusOpen
+	}
+
+	reviews, err := a.store.ListReviews(r.Context(), tenantParam(r), status)
+	if err != nil {
+		a.logg.Error("api: failed to list reviews", "error", err)
+		a.writeError(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	a.writeJSON(w, http.StatusOK, reviews)
+}
+
+func (a *API) getReviewHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := a.idParam(w, r)
+	if !ok {
+		return
+	}
+
+	review, err := a.store.GetReview(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		a.writeError(w, http.StatusNotFound, "review not found")
+		return
+	}
+	if err != nil {
+		a.logg.Error("api: failed to get review", "error", err, "id", id)
+		a.writeError(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	a.writeJSON(w, http.StatusOK, review)
+}
+
+func (a *API) resolveReviewHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := a.idParam(w, r)
+	if !ok {
+		return
+	}
+
+	var req reviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		a.writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	review, err := a.handler.ResolveReview(r.Context(), id, req.Action, req.Note)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		a.writeError(w, http.StatusNotFound, "review not found")
+	case errors.Is(err, store.ErrResolved):
+		a.writeError(w, http.StatusConflict, "review already resolved")
+	case errors.Is(err, store.ErrDuplicate):
+		a.writeError(w, http.StatusConflict, "payment already refunded or issued")
+	case errors.Is(err, handler.ErrInvalidResolution):
+		a.writeError(w, http.StatusBadRequest, err.Error())
+	case err != nil:
+		a.logg.Error("api: failed to resolve review", "error", err, "id", id)
+		a.writeError(w, http.StatusInternalServerError, "internal server error")
+	default:
+		a.writeJSON(w, http.StatusOK, review)
+	}
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/handler"
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/store"
+)
+
+type resolveRequest struct {
+	Action   string `json:"action"`
+	Tier     string `json:"tier"`
+	Quantity int    `json:"quantity"`
+	Note     string `json:"note"`
+}
+
+func (a *API) listUnmatchedHandler(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = store.UnmatchedStatusOpen
+	}
+
+	payments, err := a.store.ListUnmatchedPayments(r.Context(), tenantParam(r), status)
+	if err != nil {
+		a.logg.Error("api: failed to list unmatched payments", "error", err)
+		a.writeError(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	a.writeJSON(w, http.StatusOK, payments)
+}
+
+func (a *API) getUnmatchedHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := a.idParam(w, r)
+	if !ok {
+		return
+	}
+
+	payment, err := a.store.GetUnmatchedPayment(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		a.writeError(w, http.StatusNotFound, "unmatched payment not found")
+		return
+	}
+	if err != nil {
+		a.logg.Error("api: failed to get unmatched payment", "error", err, "id", id)
+		a.writeError(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	a.writeJSON(w, http.StatusOK, payment)
+}
+
+func (a *API) resolveUnmatchedHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := a.idParam(w, r)
+	if !ok {
+		return
+	}
+
+	var req resolveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		a.writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	payment, err := a.handler.ResolveUnmatched(r.Context(), id, handler.UnmatchedAction{
+		Action:   req.Action,
+		TierID:   req.Tier,
+		Quantity: req.Quantity,
+		Note:     req.Note,
+	})
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		a.writeError(w, http.StatusNotFound, "unmatched payment not found")
+	case errors.Is(err, store.ErrResolved):
+		a.writeError(w, http.StatusConflict, "unmatched payment already resolved")
+	case errors.Is(err, store.ErrDuplicate):
+		a.writeError(w, http.StatusConflict, "payment already refunded or issued")
+	case errors.Is(err, handler.ErrInvalidResolution):
+		a.writeError(w, http.StatusBadRequest, err.Error())
+	case err != nil:
+		a.logg.Error("api: failed to resolve unmatched payment", "error", err, "id", id)
+		a.writeError(w, http.StatusInternalServerError, "internal server error")
+	default:
+		a.writeJSON(w, http.StatusOK, payment)
+	}
+}
+
+// idParam returns the numeric id of the request path, writing an error response when it is invalid.
+func (a *API) idParam(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || id <= 0 {
+		a.writeError(w, http.StatusBadRequest, "invalid id")
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/go-chi/chi/v5"
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/handler"
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/provider"
+	"github.com/grassrootseconomics/ethutils"
+)
+
+func (a *API) listVouchersHandler(w http.ResponseWriter, r *http.Request) {
+	address, ok := a.addressParam(w, r)
+	if !ok {
+		return
+	}
+
+	sender := r.URL.Query().Get("sender")
+	if !common.IsHexAddress(sender) {
+		a.writeError(w, http.StatusBadRequest, "invalid sender address")
+		return
+	}
+
+	vouchers, err := a.handler.SenderVouchers(r.Context(), tenantParam(r), address, ethutils.ChecksumAddress(sender))
+	if err != nil {
+		a.writeProviderError(w, err, "failed to list vouchers", "vault", address, "sender", sender)
+		return
+	}
+
+	a.writeJSON(w, http.StatusOK, vouchers)
+}
+
+func (a *API) voucherStatusHandler(w http.ResponseWriter, r *http.Request) {
+	address, ok := a.addressParam(w, r)
+	if !ok {
+		return
+	}
+
+	status, err := a.handler.VoucherStatus(r.Context(), tenantParam(r), address, chi.URLParam(r, "code"))
+	if err != nil {
+		a.writeProviderError(w, err, "failed to get voucher status", "vault", address)
+		return
+	}
+
+	a.writeJSON(w, http.StatusOK, status)
+}
+
+func (a *API) revokeVoucherHandler(w http.ResponseWriter, r *http.Request) {
+	address, ok := a.addressParam(w, r)
+	if !ok {
+		return
+	}
+
+	if err := a.handler.RevokeVoucher(r.Context(), tenantParam(r), address, chi.URLParam(r, "code")); err != nil {
+		a.writeProviderError(w, err, "failed to revoke voucher", "vault", address)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *API) deleteVoucherHandler(w http.ResponseWriter, r *http.Request) {
+	address, ok := a.addressParam(w, r)
+	if !ok {
+		return
+	}
+
+	if err := a.handler.DeleteVoucher(r.Context(), tenantParam(r), address, chi.URLParam(r, "code")); err != nil {
+		a.writeProviderError(w, err, "failed to delete voucher", "vault", address)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *API) profilesHandler(w http.ResponseWriter, r *http.Request) {
+	address, ok := a.addressParam(w, r)
+	if !ok {
+		return
+	}
+
+	profiles, err := a.handler.Profiles(r.Context(), tenantParam(r), address)
+	if err != nil {
+		a.writeProviderError(w, err, "failed to list profiles", "vault", address)
+		return
+	}
+
+	a.writeJSON(w, http.StatusOK, profiles)
+}
+
+func (a *API) profileMappingsHandler(w http.ResponseWriter, r *http.Request) {
+	a.writeJSON(w, http.StatusOK, a.handler.ProfileMappings(tenantParam(r)))
+}
+
+// writeProviderError maps the errors of a voucher provider call to a response, logging unexpected ones.
+func (a *API) writeProviderError(w http.ResponseWriter, err error, msg string, args ...any) {
+	switch {
+	case errors.Is(err, handler.ErrUnknownVault):
+		a.writeError(w, http.StatusNotFound, "vault not found")
+	case errors.Is(err, provider.ErrNotFound):
+		a.writeError(w, http.StatusNotFound, "voucher not found")
+	case errors.Is(err, provider.ErrUnsupported):
+		a.writeError(w, http.StatusNotImplemented, "not supported by the vault's voucher provider")
+	default:
+		a.logg.Error("api: "+msg, append([]any{"error", err}, args...)...)
+		a.writeError(w, http.StatusBadGateway, "voucher provider error")
+	}
+}
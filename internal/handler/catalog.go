@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"context"
+	"sort"
+
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/pricing"
+)
+
+type (
+	// Catalog lists what each vault of a tenant sells and for how much.
+	Catalog struct {
+		TenantID string         `json:"tenantId"`
+		Vaults   []CatalogVault `json:"vaults"`
+	}
+
+	CatalogVault struct {
+		Address string         `json:"address"`
+		Tokens  []CatalogToken `json:"tokens"`
+		Tiers   []CatalogTier  `json:"tiers"`
+	}
+
+	CatalogToken struct {
+		Address         string `json:"address"`
+		Symbol          string `json:"symbol"`
+		Decimals        uint8  `json:"decimals"`
+		PriceMultiplier string `json:"priceMultiplier"`
+	}
+
+	// CatalogTier is an enabled tier. Amounts are in tier price units, Prices in each accepted token.
+	CatalogTier struct {
+		ID               string         `json:"id"`
+		Description      string         `json:"description"`
+		ProfilePK        int            `json:"profilePk"`
+		MinAmount        string         `json:"minAmount"`
+		MaxAmount        string         `json:"maxAmount,omitempty"`
+		SubscriptionDays int            `json:"subscriptionDays,omitempty"`
+		Prices           []CatalogPrice `json:"prices"`
+	}
+
+	// CatalogPrice is the price of a tier in a token, in token units and raw base units.
+	CatalogPrice struct {
+		Token  string `json:"token"`
+		Amount string `json:"amount"`
+		Value  string `json:"value"`
+	}
+)
+
+// Catalog builds the catalog of a tenant from the tier tables and accepted tokens the handler matches payments
+// against. Vaults and tokens are sorted by address so that an unchanged configuration gives the same catalog.
+func (h *Handler) Catalog(ctx context.Context, tenantID string) (Catalog, error) {
+	catalog := Catalog{
+		TenantID: tenantID,
+		Vaults:   []CatalogVault{},
+	}
+
+	for _, v := range h.vaults.All() {
+		if v.TenantID != tenantID {
+			continue
+		}
+
+		tokens := v.Tokens.All()
+		sort.Slice(tokens, func(i, j int) bool {
+			return tokens[i].Address < tokens[j].Address
+		})
+
+		catalogVault := CatalogVault{
+			Address: v.Address,
+			Tokens:  make([]CatalogToken, 0, len(tokens)),
+			Tiers:   []CatalogTier{},
+		}
+		decimals := make([]uint8, len(tokens))
+		for i, token := range tokens {
+			symbol, tokenDecimals, err := h.tokenDetails(ctx, token.Address)
+			if err != nil {
+				return Catalog{}, err
+			}
+			decimals[i] = tokenDecimals
+
+			catalogVault.Tokens = append(catalogVault.Tokens, CatalogToken{
+				Address:         token.Address,
+				Symbol:          symbol,
+				Decimals:        tokenDecimals,
+				PriceMultiplier: token.PriceMultiplier.RatString(),
+			})
+		}
+
+		for _, tier := range v.Tiers.All() {
+			if !tier.Enabled {
+				continue
+			}
+
+			catalogTier := CatalogTier{
+				ID:               tier.ID,
+				Description:      tier.Description,
+				ProfilePK:        v.Profiles.PK(tier),
+				MinAmount:        pricing.FormatAmount(tier.MinAmount),
+				SubscriptionDays: tier.SubscriptionDays,
+				Prices:           make([]CatalogPrice, 0, len(tokens)),
+			}
+			if tier.MaxAmount != nil {
+				catalogTier.MaxAmount = pricing.FormatAmount(tier.MaxAmount)
+			}
+			for i, token := range tokens {
+				value := token.Value(tier.MinAmount, decimals[i])
+				catalogTier.Prices = append(catalogTier.Prices, CatalogPrice{
+					Token:  token.Address,
+					Amount: pricing.FormatUnits(value, decimals[i]),
+					Value:  value.String(),
+				})
+			}
+			catalogVault.Tiers = append(catalogVault.Tiers, catalogTier)
+		}
+
+		catalog.Vaults = append(catalog.Vaults, catalogVault)
+	}
+
+	sort.Slice(catalog.Vaults, func(i, j int) bool {
+		return catalog.Vaults[i].Address < catalog.Vaults[j].Address
+	})
+
+	return catalog, nil
+}
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/pricing"
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/store"
+	"github.com/grassrootseconomics/ethutils"
+)
+
+// creditBalance returns the unspent credit of a sender in tier price units.
+func (h *Handler) creditBalance(ctx context.Context, tenantID string, senderAddress string) (*big.Rat, error) {
+	balance, err := h.store.GetCreditBalance(ctx, tenantID, ethutils.ChecksumAddress(senderAddress))
+	if err != nil {
+		return nil, err
+	}
+
+	credit, ok := new(big.Rat).SetString(balance)
+	if !ok {
+		return nil, fmt.Errorf("invalid credit balance %q for %s", balance, senderAddress)
+	}
+
+	return credit, nil
+}
+
+// partialBalance returns the sum of the sender's partial payments that are still open at the time of the event.
+func (h *Handler) partialBalance(ctx context.Context, tenantID string, senderAddress string, at time.Time) (*big.Rat, error) {
+	if h.partialPaymentWindow == 0 {
+		return new(big.Rat), nil
+	}
+
+	balance, err := h.store.GetPartialBalance(ctx, tenantID, ethutils.ChecksumAddress(senderAddress), at)
+	if err != nil {
+		return nil, err
+	}
+
+	partial, ok := new(big.Rat).SetString(balance)
+	if !ok {
+		return nil, fmt.Errorf("invalid partial payment balance %q for %s", balance, senderAddress)
+	}
+
+	return partial, nil
+}
+
+// belowThreshold reports whether an amount is held as a partial payment instead of being skipped.
+func (h *Handler) belowThreshold(tiers *pricing.Tiers, amount *big.Rat) bool {
+	minPrice := tiers.Min()
+	return h.partialPaymentWindow > 0 && minPrice != nil && amount.Cmp(minPrice) < 0
+}
+
+// settleCredit returns the ledger entries for a purchase of price paid with payment and the sender's existing
+// credit. The whole credit is spent on the purchase and anything paid above the price is credited back.
+func settleCredit(senderAddress string, payment *big.Rat, credit *big.Rat, price *big.Rat) []store.Credit {
+	var entries []store.Credit
+	senderAddress = ethutils.ChecksumAddress(senderAddress)
+
+	if credit.Sign() > 0 {
+		entries = append(entries, store.Credit{
+			SenderAddress: senderAddress,
+			Amount:        pricing.FormatAmount(new(big.Rat).Neg(credit)),
+			Kind:          store.CreditKindPurchase,
+		})
+	}
+
+	surplus := new(big.Rat).Sub(new(big.Rat).Add(payment, credit), price)
+	if surplus.Sign() > 0 {
+		entries = append(entries, store.Credit{
+			SenderAddress: senderAddress,
+			Amount:        pricing.FormatAmount(surplus),
+			Kind:          store.CreditKindOverpayment,
+		})
+	}
+
+	return entries
+}
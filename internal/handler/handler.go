@@ -2,44 +2,58 @@ package handler
 
 import (
 	"log/slog"
+	"time"
 
 	"github.com/grassrootseconomics/eth-indexer/v2/internal/cache"
 	"github.com/grassrootseconomics/eth-indexer/v2/internal/store"
-	"github.com/grassrootseconomics/eth-indexer/v2/pkg/inethi"
-	"github.com/grassrootseconomics/eth-indexer/v2/pkg/notify"
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/vault"
 	"github.com/grassrootseconomics/ethutils"
 )
 
 type (
 	HandlerOpts struct {
-		VaultAddress  string
-		Store         store.Store
-		Cache         *cache.Cache
-		ChainProvider *ethutils.Provider
-		InethiClient  *inethi.InethiClient
-		NotifyClient  *notify.NotifyClient
-		Logg          *slog.Logger
+		Vaults *vault.Registry
+		// PartialPaymentWindow enables accumulating payments below the cheapest tier, 0 disables it.
+		PartialPaymentWindow time.Duration
+		Store                store.Store
+		Cache                *cache.Cache
+		ChainProvider        *ethutils.Provider
+		Logg                 *slog.Logger
+		// Refunds sends unmatched payments resolved as refunded back to the sender on chain.
+		Refunds bool
+		// OrderTTL is how long an order reserves its transfer value, 0 disables orders.
+		OrderTTL time.Duration
+		// OrderMaxOffset is the largest number of base units added to the tier price to make an order unique.
+		OrderMaxOffset int64
+		// OrderMaxOpen caps the open orders of a vault, 0 leaves them unlimited.
+		OrderMaxOpen int
 	}
 
 	Handler struct {
-		vaultAddress  string
-		store         store.Store
-		cache         *cache.Cache
-		iClient       *inethi.InethiClient
-		nClient       *notify.NotifyClient
-		chainProvider *ethutils.Provider
-		logg          *slog.Logger
+		vaults               *vault.Registry
+		partialPaymentWindow time.Duration
+		refunds              bool
+		orderTTL             time.Duration
+		orderMaxOffset       int64
+		store                store.Store
+		cache                *cache.Cache
+		chainProvider        *ethutils.Provider
+		logg                 *slog.Logger
+		orderMaxOpen         int
 	}
 )
 
 func NewHandler(o HandlerOpts) *Handler {
 	return &Handler{
-		vaultAddress:  o.VaultAddress,
-		store:         o.Store,
-		cache:         o.Cache,
-		iClient:       o.InethiClient,
-		nClient:       o.NotifyClient,
-		chainProvider: o.ChainProvider,
-		logg:          o.Logg,
+		vaults:               o.Vaults,
+		partialPaymentWindow: o.PartialPaymentWindow,
+		refunds:              o.Refunds,
+		orderTTL:             o.OrderTTL,
+		orderMaxOffset:       o.OrderMaxOffset,
+		store:                o.Store,
+		cache:                o.Cache,
+		chainProvider:        o.ChainProvider,
+		logg:                 o.Logg,
+		orderMaxOpen:         o.OrderMaxOpen,
 	}
 }
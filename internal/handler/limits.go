@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/limits"
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/store"
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/vault"
+)
+
+// checkLimits evaluates the vault's limit rules for a purchase of vouchers worth price, paid at eventTime. A purchase
+// that breaks a rule is returned as a review, so that it is held instead of issued. Windows end at eventTime rather
+// than now, so that a backlog of transfers replayed at once is not counted against the same window.
+func (h *Handler) checkLimits(ctx context.Context, v vault.Vault, senderAddress string, vouchers int, price *big.Rat, eventTime time.Time) (*store.Review, error) {
+	if v.Limits.SenderEnabled() {
+		count, value, err := h.store.GetSenderUsage(ctx, v.TenantID, senderAddress, eventTime.Add(-v.Limits.SenderWindow), eventTime)
+		if err != nil {
+			return nil, err
+		}
+
+		spent, ok := new(big.Rat).SetString(value)
+		if !ok {
+			return nil, fmt.Errorf("invalid purchase value %q for %s", value, senderAddress)
+		}
+
+		if violation, ok := v.Limits.CheckSender(limits.Usage{Vouchers: count, Value: spent}, vouchers, price); ok {
+			return h.holdPurchase(v, senderAddress, violation), nil
+		}
+	}
+
+	if v.Limits.VelocityEnabled() {
+		count, err := h.store.GetTenantUsage(ctx, v.TenantID, eventTime.Add(-v.Limits.Window), eventTime)
+		if err != nil {
+			return nil, err
+		}
+
+		if violation, ok := v.Limits.CheckVelocity(limits.Usage{Vouchers: count}, vouchers); ok {
+			return h.holdPurchase(v, senderAddress, violation), nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (h *Handler) holdPurchase(v vault.Vault, senderAddress string, violation limits.Violation) *store.Review {
+	metrics.GetOrCreateCounter(fmt.Sprintf(`vouchers_held_total{rule=%q}`, violation.Rule)).Inc()
+	h.logg.Warn("purchase held for review", "rule", violation.Rule, "reason", violation.Reason, "tenant", v.TenantID, "sender", senderAddress)
+	return &store.Review{
+		Rule:   violation.Rule,
+		Reason: violation.Reason,
+	}
+}
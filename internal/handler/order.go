@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/pricing"
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/store"
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/vault"
+	"github.com/grassrootseconomics/eth-tracker/pkg/event"
+	"github.com/grassrootseconomics/ethutils"
+)
+
+// orderAttempts is how often CreateOrder draws another transfer value when the drawn one is already reserved.
+const orderAttempts = 10
+
+var (
+	// ErrInvalidPurchase is returned for a request that does not name a purchasable tier, vault or token.
+	ErrInvalidPurchase = errors.New("handler: invalid order")
+	// ErrOrdersExhausted is returned when a vault has no room for another open order, either because it holds the
+	// configured maximum or because no free transfer value was drawn.
+	ErrOrdersExhausted = errors.New("handler: no order available")
+)
+
+// PurchaseRequest names a tier to buy. The vault and token can be left empty when the tenant has a single
+// vault and the vault a single accepted token.
+type PurchaseRequest struct {
+	TenantID     string
+	VaultAddress string
+	TierID       string
+	TokenAddress string
+}
+
+// CreateOrder reserves a transfer value for a tier that no other open order of the vault holds. The value is the
+// tier price in the token plus a random offset of at most the configured number of base units, so that the
+// payment identifies the order.
+func (h *Handler) CreateOrder(ctx context.Context, req PurchaseRequest) (store.Order, error) {
+	p, err := h.TierPayment(ctx, req)
+	if err != nil {
+		return store.Order{}, err
+	}
+
+	if h.orderMaxOpen > 0 {
+		open, err := h.store.CountOpenOrders(ctx, p.VaultAddress)
+		if err != nil {
+			return store.Order{}, err
+		}
+		if open >= h.orderMaxOpen {
+			return store.Order{}, fmt.Errorf("%w: vault %s has %d open orders", ErrOrdersExhausted, p.VaultAddress, open)
+		}
+	}
+
+	for range orderAttempts {
+		id, err := newOrderID()
+		if err != nil {
+			return store.Order{}, err
+		}
+
+		offset, err := rand.Int(rand.Reader, big.NewInt(h.orderMaxOffset))
+		if err != nil {
+			return store.Order{}, err
+		}
+		value := new(big.Int).Add(p.Value, offset.Add(offset, big.NewInt(1)))
+
+		order := store.Order{
+			ID:              id,
+			TenantID:        p.TenantID,
+			VaultAddress:    p.VaultAddress,
+			Tier:            p.Tier.ID,
+			TierDescription: p.Tier.Description,
+			ContractAddress: p.TokenAddress,
+			TokenSymbol:     p.TokenSymbol,
+			TokenAmount:     pricing.FormatUnits(value, p.TokenDecimals),
+			Value:           value.String(),
+			Status:          store.OrderStatusOpen,
+			ExpiresAt:       time.Now().Add(h.orderTTL),
+		}
+		err = h.store.InsertOrder(ctx, &order)
+		if errors.Is(err, store.ErrDuplicate) {
+			continue
+		}
+		if err != nil {
+			return store.Order{}, err
+		}
+
+		h.logg.Info("order created", "order", order.ID, "tenant", order.TenantID, "vault", order.VaultAddress, "tier", order.Tier, "value", order.Value)
+		return order, nil
+	}
+
+	return store.Order{}, fmt.Errorf("%w: no free transfer value for tier %s after %d attempts", ErrOrdersExhausted, p.Tier.ID, orderAttempts)
+}
+
+func (h *Handler) purchaseVault(req PurchaseRequest) (vault.Vault, error) {
+	if req.VaultAddress != "" {
+		v, ok := h.vaults.Get(req.VaultAddress)
+		if !ok || v.TenantID != req.TenantID {
+			return vault.Vault{}, fmt.Errorf("%w: unknown vault %s", ErrInvalidPurchase, req.VaultAddress)
+		}
+		return v, nil
+	}
+
+	var tenantVaults []vault.Vault
+	for _, v := range h.vaults.All() {
+		if v.TenantID == req.TenantID {
+			tenantVaults = append(tenantVaults, v)
+		}
+	}
+	if len(tenantVaults) != 1 {
+		return vault.Vault{}, fmt.Errorf("%w: tenant %s has %d vaults, name one", ErrInvalidPurchase, req.TenantID, len(tenantVaults))
+	}
+	return tenantVaults[0], nil
+}
+
+func purchaseToken(v vault.Vault, tokenAddress string) (pricing.AcceptedToken, error) {
+	if tokenAddress != "" {
+		token, ok := v.Tokens.Get(tokenAddress)
+		if !ok {
+			return pricing.AcceptedToken{}, fmt.Errorf("%w: token %s is not accepted", ErrInvalidPurchase, tokenAddress)
+		}
+		return token, nil
+	}
+
+	tokens := v.Tokens.All()
+	if len(tokens) != 1 {
+		return pricing.AcceptedToken{}, fmt.Errorf("%w: vault accepts %d tokens, name one", ErrInvalidPurchase, len(tokens))
+	}
+	return tokens[0], nil
+}
+
+func newOrderID() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// matchOrder returns the open order reserving the exact value of a payment, if it was made before the order
+// expired. An order for a tier that has since been removed from the vault is ignored.
+func (h *Handler) matchOrder(ctx context.Context, v vault.Vault, event event.Event, value *big.Int, at time.Time) (string, pricing.Tier, bool, error) {
+	if h.orderTTL == 0 {
+		return "", pricing.Tier{}, false, nil
+	}
+
+	orderID, tierID, err := h.store.GetOpenOrder(ctx, v.Address, ethutils.ChecksumAddress(event.ContractAddress), value.String(), at)
+	if errors.Is(err, store.ErrNotFound) {
+		return "", pricing.Tier{}, false, nil
+	}
+	if err != nil {
+		return "", pricing.Tier{}, false, err
+	}
+
+	tier, ok := v.Tiers.Get(tierID)
+	if !ok {
+		h.logg.Warn("order tier no longer configured, matching by amount", "order", orderID, "tier", tierID)
+		return "", pricing.Tier{}, false, nil
+	}
+
+	return orderID, tier, true, nil
+}
@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/pricing"
+)
+
+// Payment is the transfer that buys a tier from a vault by amount.
+type Payment struct {
+	TenantID      string
+	VaultAddress  string
+	Tier          pricing.Tier
+	TokenAddress  string
+	TokenSymbol   string
+	TokenDecimals uint8
+	TokenAmount   string
+	Value         *big.Int
+}
+
+// TierPayment returns the transfer that buys a tier, priced from the same tier table and accepted token that
+// GenerateVoucher matches incoming payments against.
+func (h *Handler) TierPayment(ctx context.Context, req PurchaseRequest) (Payment, error) {
+	v, err := h.purchaseVault(req)
+	if err != nil {
+		return Payment{}, err
+	}
+
+	tier, ok := v.Tiers.Get(req.TierID)
+	if !ok || !tier.Enabled {
+		return Payment{}, fmt.Errorf("%w: unknown tier %q", ErrInvalidPurchase, req.TierID)
+	}
+
+	token, err := purchaseToken(v, req.TokenAddress)
+	if err != nil {
+		return Payment{}, err
+	}
+
+	tokenSymbol, tokenDecimals, err := h.tokenDetails(ctx, token.Address)
+	if err != nil {
+		return Payment{}, err
+	}
+	value := token.Value(tier.MinAmount, tokenDecimals)
+
+	return Payment{
+		TenantID:      v.TenantID,
+		VaultAddress:  v.Address,
+		Tier:          tier,
+		TokenAddress:  token.Address,
+		TokenSymbol:   tokenSymbol,
+		TokenDecimals: tokenDecimals,
+		TokenAmount:   pricing.FormatUnits(value, tokenDecimals),
+		Value:         value,
+	}, nil
+}
@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/provider"
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/store"
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/vault"
+)
+
+// ErrInvalidPool is returned for a pool import that holds malformed codes.
+var ErrInvalidPool = errors.New("handler: invalid voucher pool import")
+
+// ImportPool adds the pre-generated codes of a CSV file to the pool of a vault. Each record holds a RadiusDesk
+// profile pk and a code, an optional header line is skipped. It returns how many codes were new.
+func (h *Handler) ImportPool(ctx context.Context, tenantID string, vaultAddress string, r io.Reader) (int, error) {
+	v, err := h.tenantVault(tenantID, vaultAddress)
+	if err != nil {
+		return 0, err
+	}
+
+	codes, err := parsePoolCSV(r, v)
+	if err != nil {
+		return 0, err
+	}
+	if len(codes) == 0 {
+		return 0, fmt.Errorf("%w: no codes", ErrInvalidPool)
+	}
+
+	return h.store.ImportPoolCodes(ctx, v.Address, codes)
+}
+
+// PoolStock returns the pooled codes left per profile of a vault.
+func (h *Handler) PoolStock(ctx context.Context, tenantID string, vaultAddress string) ([]store.PoolStock, error) {
+	v, err := h.tenantVault(tenantID, vaultAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.store.ListPoolStock(ctx, v.Address)
+}
+
+func parsePoolCSV(r io.Reader, v vault.Vault) ([]store.PoolCode, error) {
+	profiles := make(map[int]bool)
+	for _, t := range v.Tiers.All() {
+		profiles[v.Profiles.PK(t)] = true
+	}
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 2
+	reader.TrimLeadingSpace = true
+
+	var codes []store.PoolCode
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return codes, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidPool, err)
+		}
+
+		profilePK, err := strconv.Atoi(strings.TrimSpace(record[0]))
+		if err != nil {
+			if line == 1 {
+				continue
+			}
+			return nil, fmt.Errorf("%w: line %d: invalid profile pk %q", ErrInvalidPool, line, record[0])
+		}
+		if !profiles[profilePK] {
+			return nil, fmt.Errorf("%w: line %d: no tier of vault %s uses profile %d", ErrInvalidPool, line, v.Address, profilePK)
+		}
+
+		code := strings.TrimSpace(record[1])
+		if code == "" {
+			return nil, fmt.Errorf("%w: line %d: empty code", ErrInvalidPool, line)
+		}
+		codes = append(codes, store.PoolCode{
+			ProfilePK: profilePK,
+			Code:      code,
+		})
+	}
+}
+
+// issueItem issues one voucher of a purchase with the vault's provider or from its pool, depending on the
+// vault's pool mode. In fallback mode the pool is only used while the provider is unavailable, and the provider
+// error is returned when the pool is empty as well. The profile is resolved again so that an item queued before a
+// profile was recreated is issued with the new one.
+func (h *Handler) issueItem(ctx context.Context, v vault.Vault, voucher store.Voucher, item store.VoucherItem) (string, error) {
+	item.ProfilePK = v.Profiles.Resolve(item.Tier, item.ProfilePK)
+
+	if v.Pool.Mode == vault.PoolOnly {
+		return h.claimPoolCode(ctx, v, item)
+	}
+
+	code, err := v.Provider.Issue(
+		ctx,
+		provider.IssueRequest{
+			SenderAddress:    voucher.SenderAddress,
+			RecipientAddress: voucher.RecipientAddress,
+			Amount:           voucher.Amount,
+			TokenSymbol:      voucher.TokenSymbol,
+			Tier:             item.Tier,
+			ProfilePK:        item.ProfilePK,
+		},
+	)
+	if v.Pool.Mode != vault.PoolFallback || !provider.Unavailable(err) {
+		return code, err
+	}
+
+	h.logg.Warn("provider failed to issue voucher, falling back to the voucher pool", "error", err, "vault", v.Address, "tier", item.Tier)
+	poolCode, poolErr := h.claimPoolCode(ctx, v, item)
+	if poolErr != nil {
+		h.logg.Error("voucher pool fallback failed", "error", poolErr, "vault", v.Address, "tier", item.Tier)
+		return "", err
+	}
+	return poolCode, nil
+}
+
+// claimPoolCode takes the next pooled code for an item and raises a low stock alert once the codes left for the
+// tier reach the vault's threshold.
+func (h *Handler) claimPoolCode(ctx context.Context, v vault.Vault, item store.VoucherItem) (string, error) {
+	code, remaining, err := h.store.ClaimPoolCode(ctx, v.Address, item.ProfilePK, item.ID)
+	if errors.Is(err, store.ErrNotFound) {
+		metrics.GetOrCreateCounter(fmt.Sprintf(`voucher_pool_empty_total{vault=%q,tier=%q}`, v.Address, item.Tier)).Inc()
+		return "", fmt.Errorf("voucher pool of vault %s has no codes left for tier %s", v.Address, item.Tier)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	metrics.GetOrCreateCounter(fmt.Sprintf(`voucher_pool_claimed_total{vault=%q,tier=%q}`, v.Address, item.Tier)).Inc()
+	metrics.GetOrCreateGauge(fmt.Sprintf(`voucher_pool_available{vault=%q,tier=%q}`, v.Address, item.Tier), nil).Set(float64(remaining))
+	if remaining <= v.Pool.LowStock {
+		metrics.GetOrCreateCounter(fmt.Sprintf(`voucher_pool_low_stock_total{vault=%q,tier=%q}`, v.Address, item.Tier)).Inc()
+		h.logg.Warn("voucher pool low on codes", "tenant", v.TenantID, "vault", v.Address, "tier", item.Tier, "profile", item.ProfilePK, "remaining", remaining)
+	}
+	return code, nil
+}
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/store"
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/vault"
+)
+
+// screenSender looks the sender up in the tenant's address registry and applies the vault's access rules.
+// Blocked senders, and unlisted senders of a restricted vault set to refuse, are refused. Unlisted senders of a
+// restricted vault set to hold get a review. Senders missing from the registry get an empty entry.
+func (h *Handler) screenSender(ctx context.Context, v vault.Vault, senderAddress string) (store.AddressEntry, bool, *store.Review, error) {
+	entry, err := h.store.GetAddress(ctx, v.TenantID, senderAddress)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return entry, false, nil, err
+	}
+
+	if entry.Blocked {
+		metrics.GetOrCreateCounter(`vouchers_refused_total{reason="blocked"}`).Inc()
+		h.logg.Warn("generate voucher refused, sender is blocked", "tenant", v.TenantID, "sender", senderAddress)
+		return entry, true, nil, nil
+	}
+
+	if v.Access.Mode != vault.AccessRestricted || entry.Allowed || entry.Member {
+		return entry, false, nil, nil
+	}
+
+	if v.Access.Unlisted == vault.UnlistedHold {
+		return entry, false, h.holdUnlisted(v, senderAddress), nil
+	}
+
+	metrics.GetOrCreateCounter(`vouchers_refused_total{reason="unlisted"}`).Inc()
+	h.logg.Warn("generate voucher refused, sender is not on the allowlist", "tenant", v.TenantID, "sender", senderAddress)
+	return entry, true, nil, nil
+}
+
+func (h *Handler) holdUnlisted(v vault.Vault, senderAddress string) *store.Review {
+	metrics.GetOrCreateCounter(fmt.Sprintf(`vouchers_held_total{rule=%q}`, vault.RuleUnlisted)).Inc()
+	h.logg.Warn("purchase held for review", "rule", vault.RuleUnlisted, "tenant", v.TenantID, "sender", senderAddress)
+	return &store.Review{
+		Rule:   vault.RuleUnlisted,
+		Reason: "sender is not on the allowlist of a restricted vault",
+	}
+}
+
+// groupPayment applies the discount of the sender's pricing group to a payment in tier price units. An unknown
+// group is logged and priced as the tier list price.
+func (h *Handler) groupPayment(v vault.Vault, entry store.AddressEntry, payment *big.Rat) *big.Rat {
+	if entry.PricingGroup == "" {
+		return payment
+	}
+
+	group, ok := v.PricingGroups.Get(entry.PricingGroup)
+	if !ok {
+		h.logg.Warn("unknown pricing group, using list price", "tenant", v.TenantID, "sender", entry.Address, "group", entry.PricingGroup)
+		return payment
+	}
+
+	return group.Apply(payment)
+}
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/store"
+	"github.com/grassrootseconomics/eth-indexer/v2/pkg/notify"
+)
+
+const (
+	// ReviewApprove releases a held purchase to the outbox, its vouchers are issued as usual.
+	ReviewApprove = "approve"
+	// ReviewReject drops a held purchase, gives back the credits and partial payments it used and refunds it on
+	// chain when refunds are enabled.
+	ReviewReject = "reject"
+)
+
+// ResolveReview approves or rejects a purchase held for review. The sender is only notified of a rejection, an
+// approved purchase is notified once its vouchers are issued. A failed notification is logged but does not fail
+// the resolution.
+func (h *Handler) ResolveReview(ctx context.Context, id int, action string, note string) (store.ReviewEntry, error) {
+	entry, err := h.store.GetReview(ctx, id)
+	if err != nil {
+		return entry, err
+	}
+	if entry.Status != store.ReviewStatusOpen {
+		return entry, store.ErrResolved
+	}
+
+	resolution := store.ReviewResolution{Note: note}
+	switch action {
+	case ReviewApprove:
+		resolution.Status = store.ReviewStatusApproved
+	case ReviewReject:
+		resolution.Status = store.ReviewStatusRejected
+		if h.refunds {
+			resolution.Refund = &store.Refund{
+				TenantID:        entry.TenantID,
+				Source:          store.RefundSourceVoucher,
+				TxHash:          entry.TxHash,
+				LogIndex:        entry.LogIndex,
+				VaultAddress:    entry.RecipientAddress,
+				SenderAddress:   entry.SenderAddress,
+				ContractAddress: entry.ContractAddress,
+				Value:           entry.TransferValue,
+			}
+		}
+	default:
+		return entry, fmt.Errorf("%w: unknown action %q", ErrInvalidResolution, action)
+	}
+
+	if err := h.store.ResolveReview(ctx, entry.ID, resolution); err != nil {
+		return entry, err
+	}
+	h.logg.Info("held purchase reviewed", "id", entry.ID, "status", resolution.Status, "sender", entry.SenderAddress, "tx_hash", entry.TxHash)
+
+	if action == ReviewReject {
+		h.notifyRejected(ctx, entry, resolution.Refund != nil)
+	}
+
+	return h.store.GetReview(ctx, entry.ID)
+}
+
+func (h *Handler) notifyRejected(ctx context.Context, entry store.ReviewEntry, refunded bool) {
+	v, ok := h.vaults.Get(entry.RecipientAddress)
+	if !ok {
+		return
+	}
+
+	message := fmt.Sprintf("Your purchase with %s %s was declined.", entry.TokenAmount, entry.TokenSymbol)
+	if refunded {
+		message = fmt.Sprintf("Your purchase with %s %s was declined and is being refunded to your wallet.", entry.TokenAmount, entry.TokenSymbol)
+	}
+
+	notifyResp, err := v.NotifyClient.SendPaymentUpdate(ctx, notify.PaymentUpdatePayload{
+		SenderAddress: entry.SenderAddress,
+		TxHash:        entry.TxHash,
+		Status:        store.ReviewStatusRejected,
+		Message:       message,
+	})
+	if err != nil {
+		h.logg.Error("failed to send payment update", "error", err, "sender", entry.SenderAddress)
+	} else {
+		h.logg.Debug("payment update sent successfully", "success", notifyResp.Success, "message", notifyResp.Message, "sender", entry.SenderAddress)
+	}
+}
@@ -0,0 +1,25 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/store"
+)
+
+// extendSubscription starts the sender's subscription for a recurring tier, or extends it from its current end
+// date when it has not expired yet. Like recording the voucher code, a failure is only logged since the voucher
+// has already been issued.
+func (h *Handler) extendSubscription(ctx context.Context, voucher store.Voucher, item store.VoucherItem) {
+	endsAt, err := h.store.ExtendSubscription(ctx, store.Subscription{
+		TenantID:        voucher.TenantID,
+		VaultAddress:    voucher.RecipientAddress,
+		SenderAddress:   voucher.SenderAddress,
+		Tier:            item.Tier,
+		TierDescription: item.Description,
+	}, item.SubscriptionDays)
+	if err != nil {
+		h.logg.Error("failed to extend subscription", "error", err, "id", voucher.ID, "sender", voucher.SenderAddress, "tier", item.Tier)
+		return
+	}
+	h.logg.Debug("subscription extended", "sender", voucher.SenderAddress, "tier", item.Tier, "ends_at", endsAt)
+}
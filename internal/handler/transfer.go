@@ -2,146 +2,314 @@ package handler
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/big"
+	"strings"
+	"time"
 
-	"github.com/grassrootseconomics/eth-indexer/v2/pkg/inethi"
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/outbox"
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/pricing"
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/store"
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/vault"
 	"github.com/grassrootseconomics/eth-indexer/v2/pkg/notify"
 	"github.com/grassrootseconomics/eth-tracker/pkg/event"
+	"github.com/grassrootseconomics/ethutils"
 	"github.com/lmittmann/w3"
 	"github.com/lmittmann/w3/module/eth"
 )
 
 func (h *Handler) IndexTransfer(ctx context.Context, event event.Event) error {
-	return h.store.InsertTokenTransfer(ctx, event)
-}
-
-const (
-	SIZE_500_MB           = 25
-	SIZE_1_GB             = 23
-	SIZE_3_GB             = 24
-	SIZE_5_GB             = 26
-	SIZE_1_MONTH_HOME     = 35
-	SIZE_1_MONTH_BUSINESS = 36
-)
+	purchase, err := h.GenerateVoucher(ctx, event)
+	if err != nil {
+		return err
+	}
 
-var (
-	// 500 MB = 10 DUNIA
-	PRICE_500_MB = big.NewInt(10_000_000)
-	// 1 GB = 20 DUNIA
-	PRICE_1_GB = big.NewInt(20_000_000)
-	// 3 GB = 50 DUNIA
-	PRICE_3_GB = big.NewInt(50_000_000)
-	// 5 GB = 80 DUNIA
-	PRICE_5_GB = big.NewInt(80_000_000)
-	// 1 Month (Home) = 5000 DUNIA
-	PRICE_1_MONTH_HOME = big.NewInt(2000_000_000)
-	// 1 Month (Business) = 5000 DUNIA
-	PRICE_1_MONTH_BUSINESS = big.NewInt(5000_000_000)
-
-	AMOUNT_DIVISOR = new(big.Float).SetInt(big.NewInt(1_000_000))
-
-	// Human-readable price tier descriptions
-	PRICE_500_MB_DESC           = "500 MB"
-	PRICE_1_GB_DESC             = "1 GB"
-	PRICE_3_GB_DESC             = "3 GB"
-	PRICE_5_GB_DESC             = "5 GB"
-	PRICE_1_MONTH_HOME_DESC     = "1 Month Home Unlimited"
-	PRICE_1_MONTH_BUSINESS_DESC = "1 Month Business Unlimited"
-)
+	if err := h.store.InsertTokenTransfer(ctx, event, purchase); err != nil {
+		return err
+	}
+	if purchase != nil && purchase.Unmatched != nil {
+		h.notifyUnmatched(ctx, *purchase.Unmatched)
+	}
+	return nil
+}
 
-func (h *Handler) GenerateVoucher(ctx context.Context, event event.Event) error {
+// GenerateVoucher turns a transfer to one of the vaults into a voucher purchase of the vault's tenant. It returns
+// nil when the transfer is not a payment. The voucher is only queued here, the outbox worker calls the provider through IssueVoucher.
+func (h *Handler) GenerateVoucher(ctx context.Context, event event.Event) (*store.Purchase, error) {
 	if !event.Success {
 		h.logg.Warn("tx reverted on chain", "tx_hash", event.TxHash)
-		return nil
+		return nil, nil
 	}
 
 	recipientAddress := event.Payload["to"].(string)
 	h.logg.Debug("generate voucher", "recipient", recipientAddress)
-	if recipientAddress != h.vaultAddress {
-		return nil
+	v, ok := h.vaults.Get(recipientAddress)
+	if !ok {
+		return nil, nil
 	}
 
-	voucherPayload := inethi.VoucherPayload{
+	rec, _ := new(big.Int).SetString(event.Payload["value"].(string), 10)
+	h.logg.Debug("generate voucher", "amount", rec)
+
+	// A redelivered event would be priced again against the credits and partials it already settled.
+	recorded, err := h.store.PaymentRecorded(ctx, event.TxHash, event.Index)
+	if err != nil {
+		return nil, err
+	}
+	if recorded {
+		h.logg.Debug("generate voucher skipped, payment already recorded", "tx_hash", event.TxHash, "log_index", event.Index)
+		return &store.Purchase{TenantID: v.TenantID}, nil
+	}
+
+	// Credits, partials, orders and unmatched payments are written from the event, so it has to be checked
+	// against the chain before anything is priced.
+	reason, err := h.verifyTransfer(ctx, chainTransfer{
+		TxHash:           event.TxHash,
+		LogIndex:         event.Index,
+		ContractAddress:  event.ContractAddress,
 		SenderAddress:    event.Payload["from"].(string),
 		RecipientAddress: recipientAddress,
+		Value:            rec.String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reason != "" {
+		metrics.GetOrCreateCounter("payments_rejected_total").Inc()
+		h.logg.Error("generate voucher skipped, payment does not match the chain", "reason", reason, "tx_hash", event.TxHash, "log_index", event.Index)
+		return nil, nil
 	}
 
-	rec, _ := new(big.Int).SetString(event.Payload["value"].(string), 10)
-	h.logg.Debug("generate voucher", "amount", rec)
+	acceptedToken, ok := v.Tokens.Get(event.ContractAddress)
+	if !ok {
+		h.logg.Warn("generate voucher skipped, unaccepted payment token", "token", event.ContractAddress, "amount", rec, "tx_hash", event.TxHash)
+		return &store.Purchase{TenantID: v.TenantID, Unaccepted: true}, nil
+	}
 
-	var tierDescription string
-	switch {
-	case rec.Cmp(PRICE_500_MB) == 0 || (rec.Cmp(PRICE_500_MB) == 1 && rec.Cmp(PRICE_1_GB) == -1):
-		voucherPayload.CouponSize = SIZE_500_MB
-		tierDescription = PRICE_500_MB_DESC
-	case rec.Cmp(PRICE_1_GB) == 0 || (rec.Cmp(PRICE_1_GB) == 1 && rec.Cmp(PRICE_3_GB) == -1):
-		voucherPayload.CouponSize = SIZE_1_GB
-		tierDescription = PRICE_1_GB_DESC
-	case rec.Cmp(PRICE_3_GB) == 0 || (rec.Cmp(PRICE_3_GB) == 1 && rec.Cmp(PRICE_5_GB) == -1):
-		voucherPayload.CouponSize = SIZE_3_GB
-		tierDescription = PRICE_3_GB_DESC
-	case rec.Cmp(PRICE_5_GB) == 0 || (rec.Cmp(PRICE_5_GB) == 1 && rec.Cmp(PRICE_1_MONTH_HOME) == -1):
-		voucherPayload.CouponSize = SIZE_5_GB
-		tierDescription = PRICE_5_GB_DESC
-	case rec.Cmp(PRICE_1_MONTH_HOME) == 0 || rec.Cmp(PRICE_1_MONTH_HOME) == 1:
-		voucherPayload.CouponSize = SIZE_1_MONTH_HOME
-		tierDescription = PRICE_1_MONTH_HOME_DESC
-	case rec.Cmp(PRICE_1_MONTH_BUSINESS) == 0 || rec.Cmp(PRICE_1_MONTH_BUSINESS) == 1:
-		voucherPayload.CouponSize = SIZE_1_MONTH_BUSINESS
-		tierDescription = PRICE_1_MONTH_BUSINESS_DESC
-	default:
-		h.logg.Info("generate voucher skipped, unrecognized amount", "amount", rec)
-		return nil
-	}
-	voucherPayload.Amount = formatAmount(rec)
-	h.logg.Debug("generate voucher", "amount", voucherPayload.Amount, "size", voucherPayload.CouponSize, "tier", tierDescription)
-
-	if h.cache.Get(event.ContractAddress) {
-		tokenSymbol, err := h.store.GetTokenSymbol(ctx, event.ContractAddress)
-		if err != nil {
-			return err
-		}
-		voucherPayload.TokenSymbol = tokenSymbol
-	} else {
-		var tokenSymbol string
+	tokenSymbol, tokenDecimals, err := h.tokenDetails(ctx, event.ContractAddress)
+	if err != nil {
+		return nil, err
+	}
 
-		contractAddress := w3.A(event.ContractAddress)
+	senderAddress := event.Payload["from"].(string)
+	entry, refused, review, err := h.screenSender(ctx, v, ethutils.ChecksumAddress(senderAddress))
+	if err != nil {
+		return nil, err
+	}
+	if refused {
+		return &store.Purchase{
+			TenantID:  v.TenantID,
+			Unmatched: unmatchedPayment(event, v, senderAddress, rec, tokenSymbol, tokenDecimals, acceptedToken.Normalise(rec, tokenDecimals), unmatchedReasonRefused),
+		}, nil
+	}
 
-		if err := h.chainProvider.Client.CallCtx(
-			ctx,
-			eth.CallFunc(contractAddress, symbolGetter).Returns(&tokenSymbol),
-		); err != nil {
-			return err
+	eventTime := time.Unix(int64(event.Timestamp), 0)
+	orderID, orderTier, ok, err := h.matchOrder(ctx, v, event, rec, eventTime)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		if review == nil {
+			review, err = h.checkLimits(ctx, v, ethutils.ChecksumAddress(senderAddress), 1, orderTier.MinAmount, eventTime)
+			if err != nil {
+				return nil, err
+			}
 		}
-		voucherPayload.TokenSymbol = tokenSymbol
+
+		voucher := newVoucher(event, v, senderAddress, rec, tokenSymbol, tokenDecimals, []pricing.Tier{orderTier})
+		h.logg.Debug("generate voucher for order", "order", orderID, "amount", voucher.Amount, "tier", orderTier.ID)
+		return &store.Purchase{
+			TenantID: v.TenantID,
+			Voucher:  voucher,
+			OrderID:  orderID,
+			Review:   review,
+		}, nil
 	}
 
-	resp, err := h.iClient.GenerateVoucher(
-		ctx,
-		voucherPayload,
-	)
+	payment := h.groupPayment(v, entry, acceptedToken.Normalise(rec, tokenDecimals))
+
+	credit, err := h.creditBalance(ctx, v.TenantID, senderAddress)
 	if err != nil {
+		return nil, err
+	}
+
+	partial, err := h.partialBalance(ctx, v.TenantID, senderAddress, eventTime)
+	if err != nil {
+		return nil, err
+	}
+	paid := new(big.Rat).Add(payment, partial)
+
+	available := new(big.Rat).Add(paid, credit)
+	tiers, ok := v.Tiers.Decompose(available, v.Decomposition)
+	if !ok {
+		if h.belowThreshold(v.Tiers, available) {
+			h.logg.Info("generate voucher deferred, holding partial payment", "amount", rec, "sender", senderAddress, "total", available.FloatString(pricing.AmountPrecision))
+			return &store.Purchase{
+				TenantID: v.TenantID,
+				PartialPayment: &store.PartialPayment{
+					SenderAddress: ethutils.ChecksumAddress(senderAddress),
+					Amount:        pricing.FormatAmount(payment),
+					ExpiresAt:     eventTime.Add(h.partialPaymentWindow),
+				},
+			}, nil
+		}
+
+		h.logg.Info("generate voucher skipped, unrecognized amount, recording unmatched payment", "amount", rec, "credit", credit.FloatString(pricing.AmountPrecision))
+		return &store.Purchase{
+			TenantID:  v.TenantID,
+			Unmatched: unmatchedPayment(event, v, senderAddress, rec, tokenSymbol, tokenDecimals, payment, unmatchedReasonNoTier),
+		}, nil
+	}
+
+	price := pricing.Price(tiers)
+	if review == nil {
+		review, err = h.checkLimits(ctx, v, ethutils.ChecksumAddress(senderAddress), len(tiers), price, eventTime)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	voucher := newVoucher(event, v, senderAddress, rec, tokenSymbol, tokenDecimals, tiers)
+	h.logg.Debug("generate voucher", "amount", voucher.Amount, "vouchers", len(voucher.Items), "policy", v.Decomposition.Policy)
+
+	return &store.Purchase{
+		TenantID:       v.TenantID,
+		Voucher:        voucher,
+		Credits:        settleCredit(senderAddress, paid, credit, price),
+		SettlePartials: partial.Sign() > 0,
+		Review:         review,
+	}, nil
+}
+
+// newVoucher queues one voucher item per tier for a payment to v.
+func newVoucher(event event.Event, v vault.Vault, senderAddress string, value *big.Int, tokenSymbol string, tokenDecimals uint8, tiers []pricing.Tier) *store.Voucher {
+	return &store.Voucher{
+		TenantID:         v.TenantID,
+		TxHash:           event.TxHash,
+		LogIndex:         event.Index,
+		SenderAddress:    ethutils.ChecksumAddress(senderAddress),
+		RecipientAddress: v.Address,
+		ContractAddress:  event.ContractAddress,
+		TransferValue:    value.String(),
+		Amount:           pricing.FormatUnits(value, tokenDecimals),
+		TokenSymbol:      tokenSymbol,
+		Price:            pricing.FormatAmount(pricing.Price(tiers)),
+		BlockNumber:      event.Block,
+		Items:            voucherItems(v, tiers),
+	}
+}
+
+func voucherItems(v vault.Vault, tiers []pricing.Tier) []store.VoucherItem {
+	items := make([]store.VoucherItem, 0, len(tiers))
+	for _, tier := range tiers {
+		items = append(items, store.VoucherItem{
+			Tier:             tier.ID,
+			ProfilePK:        v.Profiles.PK(tier),
+			Description:      tier.Description,
+			SubscriptionDays: tier.SubscriptionDays,
+		})
+	}
+	return items
+}
+
+// IssueVoucher verifies the payment of a purchase drained from the outbox against the chain, generates its
+// vouchers with the vault's provider or pool and notifies the sender. Items that already carry a code were issued
+// by an earlier attempt and are not generated again. A failed notification is logged but does not fail the
+// purchase.
+func (h *Handler) IssueVoucher(ctx context.Context, voucher store.Voucher) error {
+	v, ok := h.vaults.Get(voucher.RecipientAddress)
+	if !ok {
+		return fmt.Errorf("%w: %s is no longer a configured vault", outbox.ErrRejected, voucher.RecipientAddress)
+	}
+
+	if err := h.verifyPayment(ctx, voucher); err != nil {
 		return err
 	}
-	h.logg.Debug("voucher generated", "voucher", resp.Voucher, "sender", voucherPayload.SenderAddress, "recipient", voucherPayload.RecipientAddress, "amount", voucherPayload.Amount, "token", voucherPayload.TokenSymbol)
 
-	notifyResp, err := h.nClient.SendNotification(ctx, notify.NotifyPayload{
-		SenderAddress: voucherPayload.SenderAddress,
-		Code:          resp.Voucher,
-		Size:          tierDescription,
+	codes := make([]string, 0, len(voucher.Items))
+
+	for _, item := range voucher.Items {
+		if item.Code != "" {
+			codes = append(codes, item.Code)
+			continue
+		}
+
+		code, err := h.issueItem(ctx, v, voucher, item)
+		if err != nil {
+			return err
+		}
+		h.logg.Debug("voucher generated", "voucher", code, "sender", voucher.SenderAddress, "recipient", voucher.RecipientAddress, "amount", voucher.Amount, "token", voucher.TokenSymbol, "tier", item.Tier)
+
+		if err := h.store.SetVoucherItemIssued(ctx, item.ID, code); err != nil {
+			// Returning here would make the retry generate this voucher a second time.
+			h.logg.Error("failed to record issued voucher", "error", err, "id", voucher.ID, "voucher", code)
+		}
+		if item.SubscriptionDays > 0 {
+			h.extendSubscription(ctx, voucher, item)
+		}
+		codes = append(codes, code)
+	}
+
+	size := describeItems(voucher.Items)
+	notifyResp, err := v.NotifyClient.SendNotification(ctx, notify.NotifyPayload{
+		SenderAddress: voucher.SenderAddress,
+		Code:          codes[0],
+		Codes:         codes,
+		Size:          size,
 	})
 	if err != nil {
-		h.logg.Error("failed to send notification", "error", err, "sender", voucherPayload.SenderAddress)
+		h.logg.Error("failed to send notification", "error", err, "sender", voucher.SenderAddress)
 	} else {
-		h.logg.Debug("notification sent successfully", "success", notifyResp.Success, "message", notifyResp.Message, "sender", voucherPayload.SenderAddress, "tier", tierDescription)
+		h.logg.Debug("notification sent successfully", "success", notifyResp.Success, "message", notifyResp.Message, "sender", voucher.SenderAddress, "tier", size)
 	}
 
 	return nil
 }
 
-func formatAmount(dividend *big.Int) string {
-	floatDividend := new(big.Float).SetInt(dividend)
-	result := new(big.Float).Quo(floatDividend, AMOUNT_DIVISOR)
-	return fmt.Sprintf("%.8f", result)
+// describeItems summarises the vouchers of a purchase for the sender, e.g. "10 x 1 GB" or "5 GB, 1 GB".
+func describeItems(items []store.VoucherItem) string {
+	var (
+		parts []string
+		count int
+	)
+
+	for i, item := range items {
+		count++
+		if i+1 < len(items) && items[i+1].Description == item.Description {
+			continue
+		}
+
+		if count > 1 {
+			parts = append(parts, fmt.Sprintf("%d x %s", count, item.Description))
+		} else {
+			parts = append(parts, item.Description)
+		}
+		count = 0
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// tokenDetails returns the symbol and decimals of a token, preferring the copy indexed by AddToken. AddToken runs
+// concurrently with IndexTransfer, so the token is read from the chain when it has not been stored yet.
+func (h *Handler) tokenDetails(ctx context.Context, contractAddress string) (string, uint8, error) {
+	tokenSymbol, tokenDecimals, err := h.store.GetToken(ctx, contractAddress)
+	if err == nil {
+		return tokenSymbol, tokenDecimals, nil
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		return "", 0, err
+	}
+
+	if err := h.chainProvider.Client.CallCtx(
+		ctx,
+		eth.CallFunc(w3.A(contractAddress), symbolGetter).Returns(&tokenSymbol),
+		eth.CallFunc(w3.A(contractAddress), decimalsGetter).Returns(&tokenDecimals),
+	); err != nil {
+		return "", 0, err
+	}
+
+	return tokenSymbol, tokenDecimals, nil
 }
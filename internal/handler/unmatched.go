@@ -0,0 +1,220 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/pricing"
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/store"
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/vault"
+	"github.com/grassrootseconomics/eth-indexer/v2/pkg/notify"
+	"github.com/grassrootseconomics/eth-tracker/pkg/event"
+	"github.com/grassrootseconomics/ethutils"
+)
+
+const (
+	unmatchedReasonNoTier  = "no matching tier"
+	unmatchedReasonRefused = "sender refused"
+
+	// ResolveIssue queues vouchers of a chosen tier for an unmatched payment.
+	ResolveIssue = "issue"
+	// ResolveCredit adds an unmatched payment to the sender's credit balance.
+	ResolveCredit = "credit"
+	// ResolveRefund returns an unmatched payment to the sender, on chain when refunds are enabled and otherwise
+	// only recording that it was refunded by hand.
+	ResolveRefund = "refund"
+)
+
+// ErrInvalidResolution is returned by ResolveUnmatched for an action that can not be applied to the payment.
+var ErrInvalidResolution = errors.New("handler: invalid resolution")
+
+// UnmatchedAction is how an admin resolves an unmatched payment. TierID and Quantity only apply to ResolveIssue.
+type UnmatchedAction struct {
+	Action   string
+	TierID   string
+	Quantity int
+	Note     string
+}
+
+// unmatchedPayment records an accepted payment that bought nothing, so that it can be resolved later. Amount is
+// the payment in tier price units.
+func unmatchedPayment(event event.Event, v vault.Vault, senderAddress string, value *big.Int, tokenSymbol string, tokenDecimals uint8, amount *big.Rat, reason string) *store.UnmatchedPayment {
+	return &store.UnmatchedPayment{
+		TenantID:         v.TenantID,
+		TxHash:           event.TxHash,
+		LogIndex:         event.Index,
+		SenderAddress:    ethutils.ChecksumAddress(senderAddress),
+		RecipientAddress: v.Address,
+		ContractAddress:  event.ContractAddress,
+		TransferValue:    value.String(),
+		TokenAmount:      pricing.FormatUnits(value, tokenDecimals),
+		TokenSymbol:      tokenSymbol,
+		Amount:           pricing.FormatAmount(amount),
+		BlockNumber:      event.Block,
+		Reason:           reason,
+	}
+}
+
+// notifyUnmatched tells the sender that a payment bought nothing and is held until it is resolved. It is sent once
+// the payment is recorded, a failed notification is logged but does not fail the transfer.
+func (h *Handler) notifyUnmatched(ctx context.Context, payment store.UnmatchedPayment) {
+	v, ok := h.vaults.Get(payment.RecipientAddress)
+	if !ok {
+		return
+	}
+
+	message := fmt.Sprintf("Your payment of %s %s did not match a voucher and is being reviewed.", payment.TokenAmount, payment.TokenSymbol)
+	if payment.Reason == unmatchedReasonRefused {
+		message = fmt.Sprintf("Your payment of %s %s can not buy vouchers from this vault and is being reviewed.", payment.TokenAmount, payment.TokenSymbol)
+	}
+
+	notifyResp, err := v.NotifyClient.SendPaymentUpdate(ctx, notify.PaymentUpdatePayload{
+		SenderAddress: payment.SenderAddress,
+		TxHash:        payment.TxHash,
+		Status:        store.UnmatchedStatusOpen,
+		Message:       message,
+	})
+	if err != nil {
+		h.logg.Error("failed to send payment update", "error", err, "sender", payment.SenderAddress)
+	} else {
+		h.logg.Debug("payment update sent successfully", "success", notifyResp.Success, "message", notifyResp.Message, "sender", payment.SenderAddress)
+	}
+}
+
+// ResolveUnmatched closes an open unmatched payment and notifies the sender. A failed notification is logged but
+// does not fail the resolution.
+func (h *Handler) ResolveUnmatched(ctx context.Context, id int, action UnmatchedAction) (store.UnmatchedPayment, error) {
+	payment, err := h.store.GetUnmatchedPayment(ctx, id)
+	if err != nil {
+		return payment, err
+	}
+	if payment.Status != store.UnmatchedStatusOpen {
+		return payment, store.ErrResolved
+	}
+
+	v, ok := h.vaults.Get(payment.RecipientAddress)
+	if !ok {
+		return payment, fmt.Errorf("%w: %s is no longer a configured vault", ErrInvalidResolution, payment.RecipientAddress)
+	}
+
+	resolution := store.Resolution{
+		TenantID: payment.TenantID,
+		Note:     action.Note,
+	}
+
+	var message string
+	switch action.Action {
+	case ResolveIssue:
+		tier, ok := v.Tiers.Get(action.TierID)
+		if !ok {
+			return payment, fmt.Errorf("%w: unknown tier %q", ErrInvalidResolution, action.TierID)
+		}
+		quantity := max(action.Quantity, 1)
+		// An admin may exchange a short payment for one voucher, but never for more vouchers than it pays for.
+		amount, ok := new(big.Rat).SetString(payment.Amount)
+		if !ok {
+			return payment, fmt.Errorf("invalid unmatched payment amount %q", payment.Amount)
+		}
+		covered := max(tier.Count(amount), 1)
+		if v.Decomposition.MaxVouchers > 0 {
+			covered = min(covered, v.Decomposition.MaxVouchers)
+		}
+		if quantity > covered {
+			return payment, fmt.Errorf("%w: payment of %s covers %d vouchers of tier %s, not %d", ErrInvalidResolution, payment.Amount, covered, tier.ID, quantity)
+		}
+
+		voucher := &store.Voucher{
+			TenantID:         payment.TenantID,
+			TxHash:           payment.TxHash,
+			LogIndex:         payment.LogIndex,
+			SenderAddress:    payment.SenderAddress,
+			RecipientAddress: payment.RecipientAddress,
+			ContractAddress:  payment.ContractAddress,
+			TransferValue:    payment.TransferValue,
+			Amount:           payment.TokenAmount,
+			TokenSymbol:      payment.TokenSymbol,
+			BlockNumber:      payment.BlockNumber,
+		}
+		tiers := repeatTier(tier, quantity)
+		voucher.Items = voucherItems(v, tiers)
+		voucher.Price = pricing.FormatAmount(pricing.Price(tiers))
+
+		resolution.Status = store.UnmatchedStatusIssued
+		resolution.Voucher = voucher
+		message = fmt.Sprintf("Your payment of %s %s did not match a voucher and has been exchanged for %s.", payment.TokenAmount, payment.TokenSymbol, describeItems(voucher.Items))
+	case ResolveCredit:
+		resolution.Status = store.UnmatchedStatusCredited
+		resolution.Credit = &store.Credit{
+			SenderAddress: payment.SenderAddress,
+			Amount:        payment.Amount,
+			Kind:          store.CreditKindUnmatched,
+			TxHash:        payment.TxHash,
+			LogIndex:      payment.LogIndex,
+		}
+		message = fmt.Sprintf("Your payment of %s %s did not match a voucher and has been added to your credit for the next purchase.", payment.TokenAmount, payment.TokenSymbol)
+	case ResolveRefund:
+		resolution.Status = store.UnmatchedStatusRefunded
+		message = fmt.Sprintf("Your payment of %s %s did not match a voucher and has been refunded.", payment.TokenAmount, payment.TokenSymbol)
+		if h.refunds {
+			resolution.Refund = &store.Refund{
+				TenantID:        payment.TenantID,
+				Source:          store.RefundSourceUnmatched,
+				TxHash:          payment.TxHash,
+				LogIndex:        payment.LogIndex,
+				VaultAddress:    payment.RecipientAddress,
+				SenderAddress:   payment.SenderAddress,
+				ContractAddress: payment.ContractAddress,
+				Value:           payment.TransferValue,
+			}
+			message = fmt.Sprintf("Your payment of %s %s did not match a voucher and is being refunded to your wallet.", payment.TokenAmount, payment.TokenSymbol)
+		}
+	default:
+		return payment, fmt.Errorf("%w: unknown action %q", ErrInvalidResolution, action.Action)
+	}
+
+	// An unmatched payment is only ever backed by its tracker event, check it on chain before it is exchanged,
+	// credited or refunded.
+	reason, err := h.verifyTransfer(ctx, chainTransfer{
+		TxHash:           payment.TxHash,
+		LogIndex:         payment.LogIndex,
+		ContractAddress:  payment.ContractAddress,
+		SenderAddress:    payment.SenderAddress,
+		RecipientAddress: payment.RecipientAddress,
+		Value:            payment.TransferValue,
+	})
+	if err != nil {
+		return payment, err
+	}
+	if reason != "" {
+		return payment, fmt.Errorf("%w: payment not verified on chain: %s", ErrInvalidResolution, reason)
+	}
+
+	if err := h.store.ResolveUnmatchedPayment(ctx, payment.ID, resolution); err != nil {
+		return payment, err
+	}
+	h.logg.Info("unmatched payment resolved", "id", payment.ID, "status", resolution.Status, "sender", payment.SenderAddress, "tx_hash", payment.TxHash)
+
+	notifyResp, err := v.NotifyClient.SendPaymentUpdate(ctx, notify.PaymentUpdatePayload{
+		SenderAddress: payment.SenderAddress,
+		TxHash:        payment.TxHash,
+		Status:        resolution.Status,
+		Message:       message,
+	})
+	if err != nil {
+		h.logg.Error("failed to send payment update", "error", err, "sender", payment.SenderAddress)
+	} else {
+		h.logg.Debug("payment update sent successfully", "success", notifyResp.Success, "message", notifyResp.Message, "sender", payment.SenderAddress)
+	}
+
+	return h.store.GetUnmatchedPayment(ctx, payment.ID)
+}
+
+func repeatTier(tier pricing.Tier, quantity int) []pricing.Tier {
+	tiers := make([]pricing.Tier, quantity)
+	for i := range tiers {
+		tiers[i] = tier
+	}
+	return tiers
+}
@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/outbox"
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/store"
+	"github.com/lmittmann/w3"
+	"github.com/lmittmann/w3/module/eth"
+)
+
+var transferEvent = w3.MustNewEvent("Transfer(address indexed from, address indexed to, uint256 value)")
+
+// chainTransfer is a token transfer as claimed by a tracker event, to be checked against its receipt.
+type chainTransfer struct {
+	TxHash           string
+	LogIndex         uint
+	ContractAddress  string
+	SenderAddress    string
+	RecipientAddress string
+	Value            string
+}
+
+// verifyPayment checks a queued purchase against the transaction receipt on chain, so that a forged tracker
+// event can not buy vouchers. A mismatch is returned as outbox.ErrRejected.
+func (h *Handler) verifyPayment(ctx context.Context, voucher store.Voucher) error {
+	reason, err := h.verifyTransfer(ctx, chainTransfer{
+		TxHash:           voucher.TxHash,
+		LogIndex:         voucher.LogIndex,
+		ContractAddress:  voucher.ContractAddress,
+		SenderAddress:    voucher.SenderAddress,
+		RecipientAddress: voucher.RecipientAddress,
+		Value:            voucher.TransferValue,
+	})
+	if err != nil {
+		return err
+	}
+	if reason != "" {
+		return h.rejectPayment(voucher, reason)
+	}
+	return nil
+}
+
+// verifyTransfer looks up the receipt of a transfer and returns why it does not match, or an empty reason when
+// the log at the transfer's index is exactly the claimed transfer. Matching the log index keeps a single payment
+// from being claimed once per forged log index.
+func (h *Handler) verifyTransfer(ctx context.Context, t chainTransfer) (string, error) {
+	var receipt *types.Receipt
+	if err := h.chainProvider.Client.CallCtx(
+		ctx,
+		eth.TxReceipt(common.HexToHash(t.TxHash)).Returns(&receipt),
+	); err != nil {
+		return "", err
+	}
+
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return "transaction reverted on chain", nil
+	}
+
+	value, ok := new(big.Int).SetString(t.Value, 10)
+	if !ok {
+		return fmt.Sprintf("invalid transfer value %s", t.Value), nil
+	}
+
+	var (
+		contractAddress  = common.HexToAddress(t.ContractAddress)
+		senderAddress    = common.HexToAddress(t.SenderAddress)
+		recipientAddress = common.HexToAddress(t.RecipientAddress)
+	)
+	for _, log := range receipt.Logs {
+		if log.Index != t.LogIndex {
+			continue
+		}
+		if log.Address != contractAddress || len(log.Topics) != 3 || log.Topics[0] != transferEvent.Topic0 {
+			break
+		}
+
+		var (
+			from, to common.Address
+			amount   big.Int
+		)
+		if err := transferEvent.DecodeArgs(log, &from, &to, &amount); err != nil {
+			break
+		}
+
+		if from == senderAddress && to == recipientAddress && amount.Cmp(value) == 0 {
+			return "", nil
+		}
+		break
+	}
+
+	return "no matching transfer log in receipt", nil
+}
+
+func (h *Handler) rejectPayment(voucher store.Voucher, reason string) error {
+	metrics.GetOrCreateCounter("vouchers_rejected_total").Inc()
+	h.logg.Error("payment verification failed, voucher rejected", "reason", reason, "id", voucher.ID, "tx_hash", voucher.TxHash, "sender", voucher.SenderAddress, "value", voucher.TransferValue)
+	return fmt.Errorf("%w: %s", outbox.ErrRejected, reason)
+}
@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/provider"
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/vault"
+	"github.com/grassrootseconomics/eth-indexer/v2/pkg/inethi"
+)
+
+// ErrUnknownVault is returned for a vault address that is not a vault of the requested tenant.
+var ErrUnknownVault = errors.New("handler: unknown vault")
+
+// VoucherStatus asks the vault's provider whether a voucher code was used.
+func (h *Handler) VoucherStatus(ctx context.Context, tenantID string, vaultAddress string, code string) (provider.Status, error) {
+	v, err := h.tenantVault(tenantID, vaultAddress)
+	if err != nil {
+		return provider.Status{}, err
+	}
+
+	return v.Provider.Status(ctx, code)
+}
+
+// RevokeVoucher disables a voucher code issued by mistake.
+func (h *Handler) RevokeVoucher(ctx context.Context, tenantID string, vaultAddress string, code string) error {
+	v, err := h.tenantVault(tenantID, vaultAddress)
+	if err != nil {
+		return err
+	}
+
+	if err := v.Provider.Revoke(ctx, code); err != nil {
+		return err
+	}
+	h.logg.Info("voucher revoked", "tenant", tenantID, "vault", v.Address, "voucher", code)
+	return nil
+}
+
+// DeleteVoucher removes a voucher code from iNethi. Other providers return provider.ErrUnsupported.
+func (h *Handler) DeleteVoucher(ctx context.Context, tenantID string, vaultAddress string, code string) error {
+	p, err := h.inethiProvider(tenantID, vaultAddress)
+	if err != nil {
+		return err
+	}
+
+	if err := p.Delete(ctx, code); err != nil {
+		return err
+	}
+	h.logg.Info("voucher deleted", "tenant", tenantID, "vault", vaultAddress, "voucher", code)
+	return nil
+}
+
+// SenderVouchers lists the vouchers iNethi generated for a sender. Other providers return
+// provider.ErrUnsupported.
+func (h *Handler) SenderVouchers(ctx context.Context, tenantID string, vaultAddress string, senderAddress string) ([]inethi.Voucher, error) {
+	p, err := h.inethiProvider(tenantID, vaultAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.Vouchers(ctx, senderAddress)
+}
+
+// Profiles lists the RadiusDesk profiles of the vault's site. Other providers return provider.ErrUnsupported.
+func (h *Handler) Profiles(ctx context.Context, tenantID string, vaultAddress string) ([]inethi.Profile, error) {
+	p, err := h.inethiProvider(tenantID, vaultAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.Profiles(ctx)
+}
+
+func (h *Handler) tenantVault(tenantID string, vaultAddress string) (vault.Vault, error) {
+	v, ok := h.vaults.Get(vaultAddress)
+	if !ok || v.TenantID != tenantID {
+		return vault.Vault{}, fmt.Errorf("%w: %s is not a vault of tenant %s", ErrUnknownVault, vaultAddress, tenantID)
+	}
+	return v, nil
+}
+
+func (h *Handler) inethiProvider(tenantID string, vaultAddress string) (*provider.Inethi, error) {
+	v, err := h.tenantVault(tenantID, vaultAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	p, ok := v.Provider.(*provider.Inethi)
+	if !ok {
+		return nil, provider.ErrUnsupported
+	}
+	return p, nil
+}
+
+// VaultProfiles is the tier to RadiusDesk profile mapping of a vault as of its last profile sync.
+type VaultProfiles struct {
+	VaultAddress string                 `json:"vaultAddress"`
+	SyncedAt     *time.Time             `json:"syncedAt"`
+	Tiers        []vault.ProfileMapping `json:"tiers"`
+}
+
+// ProfileMappings returns the synced profile mapping of every vault of a tenant, sorted by vault address.
+func (h *Handler) ProfileMappings(tenantID string) []VaultProfiles {
+	result := make([]VaultProfiles, 0)
+	for _, v := range h.vaults.All() {
+		if v.TenantID != tenantID {
+			continue
+		}
+
+		mappings, syncedAt := v.Profiles.Mappings()
+		vaultProfiles := VaultProfiles{
+			VaultAddress: v.Address,
+			Tiers:        mappings,
+		}
+		if !syncedAt.IsZero() {
+			vaultProfiles.SyncedAt = &syncedAt
+		}
+		result = append(result, vaultProfiles)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].VaultAddress < result[j].VaultAddress
+	})
+	return result
+}
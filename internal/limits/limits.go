@@ -0,0 +1,99 @@
+package limits
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+)
+
+const (
+	RuleSenderVouchers = "sender_vouchers"
+	RuleSenderValue    = "sender_value"
+	RuleVelocity       = "velocity"
+)
+
+type (
+	// Rules bound how many vouchers can be bought, so that a compromised wallet can not drain its balance into
+	// vouchers for resale. Zero or nil limits are disabled.
+	Rules struct {
+		// MaxSenderVouchers and MaxSenderValue, in tier price units, apply to a single sender over SenderWindow.
+		MaxSenderVouchers int
+		MaxSenderValue    *big.Rat
+		SenderWindow      time.Duration
+		// MaxVouchers applies to all senders of a tenant together over Window.
+		MaxVouchers int
+		Window      time.Duration
+	}
+
+	// Usage is what has already been bought within a rule's window.
+	Usage struct {
+		Vouchers int
+		Value    *big.Rat
+	}
+
+	// Violation names the rule a purchase breaks and why.
+	Violation struct {
+		Rule   string
+		Reason string
+	}
+)
+
+func (r Rules) Validate() error {
+	if r.MaxSenderVouchers < 0 || r.MaxVouchers < 0 {
+		return fmt.Errorf("limits: voucher limits can not be negative")
+	}
+	if r.MaxSenderValue != nil && r.MaxSenderValue.Sign() <= 0 {
+		return fmt.Errorf("limits: max sender value must be positive")
+	}
+	if (r.MaxSenderVouchers > 0 || r.MaxSenderValue != nil) && r.SenderWindow <= 0 {
+		return fmt.Errorf("limits: sender limits need a positive sender window")
+	}
+	if r.MaxVouchers > 0 && r.Window <= 0 {
+		return fmt.Errorf("limits: velocity limit needs a positive window")
+	}
+	return nil
+}
+
+// SenderEnabled reports whether any per sender rule is configured.
+func (r Rules) SenderEnabled() bool {
+	return r.MaxSenderVouchers > 0 || r.MaxSenderValue != nil
+}
+
+// VelocityEnabled reports whether the rule across all senders is configured.
+func (r Rules) VelocityEnabled() bool {
+	return r.MaxVouchers > 0
+}
+
+// CheckSender checks a purchase of vouchers worth value against what the sender already bought.
+func (r Rules) CheckSender(usage Usage, vouchers int, value *big.Rat) (Violation, bool) {
+	if r.MaxSenderVouchers > 0 && usage.Vouchers+vouchers > r.MaxSenderVouchers {
+		return Violation{
+			Rule:   RuleSenderVouchers,
+			Reason: fmt.Sprintf("sender would hold %d vouchers within %s, limit is %d", usage.Vouchers+vouchers, r.SenderWindow, r.MaxSenderVouchers),
+		}, true
+	}
+
+	if r.MaxSenderValue != nil {
+		total := new(big.Rat).Add(usage.Value, value)
+		if total.Cmp(r.MaxSenderValue) > 0 {
+			return Violation{
+				Rule:   RuleSenderValue,
+				Reason: fmt.Sprintf("sender would spend %s within %s, limit is %s", total.RatString(), r.SenderWindow, r.MaxSenderValue.RatString()),
+			}, true
+		}
+	}
+
+	return Violation{}, false
+}
+
+// CheckVelocity checks a purchase of vouchers against what all senders of the tenant already bought.
+func (r Rules) CheckVelocity(usage Usage, vouchers int) (Violation, bool) {
+	if r.MaxVouchers > 0 && usage.Vouchers+vouchers > r.MaxVouchers {
+		return Violation{
+			Rule:   RuleVelocity,
+			Reason: fmt.Sprintf("%d vouchers within %s across all senders, limit is %d", usage.Vouchers+vouchers, r.Window, r.MaxVouchers),
+		}, true
+	}
+
+	return Violation{}, false
+}
@@ -0,0 +1,95 @@
+package limits
+
+import (
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules Rules
+		valid bool
+	}{
+		{name: "disabled", rules: Rules{}, valid: true},
+		{name: "sender vouchers", rules: Rules{MaxSenderVouchers: 5, SenderWindow: time.Hour}, valid: true},
+		{name: "sender value", rules: Rules{MaxSenderValue: big.NewRat(100, 1), SenderWindow: time.Hour}, valid: true},
+		{name: "velocity", rules: Rules{MaxVouchers: 100, Window: time.Minute}, valid: true},
+		{name: "negative sender vouchers", rules: Rules{MaxSenderVouchers: -1, SenderWindow: time.Hour}},
+		{name: "negative velocity", rules: Rules{MaxVouchers: -1, Window: time.Hour}},
+		{name: "zero sender value", rules: Rules{MaxSenderValue: new(big.Rat), SenderWindow: time.Hour}},
+		{name: "sender limit without window", rules: Rules{MaxSenderVouchers: 5}},
+		{name: "sender value without window", rules: Rules{MaxSenderValue: big.NewRat(100, 1)}},
+		{name: "velocity without window", rules: Rules{MaxVouchers: 100}},
+	}
+
+	for _, tt := range tests {
+		if err := tt.rules.Validate(); (err == nil) != tt.valid {
+			t.Errorf("%s: Validate() = %v, want valid %v", tt.name, err, tt.valid)
+		}
+	}
+}
+
+func TestCheckSender(t *testing.T) {
+	rules := Rules{
+		MaxSenderVouchers: 5,
+		MaxSenderValue:    big.NewRat(100, 1),
+		SenderWindow:      time.Hour,
+	}
+
+	tests := []struct {
+		name     string
+		usage    Usage
+		vouchers int
+		value    *big.Rat
+		rule     string
+	}{
+		{name: "first purchase", usage: Usage{Value: new(big.Rat)}, vouchers: 1, value: big.NewRat(10, 1)},
+		{name: "up to the voucher limit", usage: Usage{Vouchers: 4, Value: big.NewRat(40, 1)}, vouchers: 1, value: big.NewRat(10, 1)},
+		{name: "over the voucher limit", usage: Usage{Vouchers: 4, Value: big.NewRat(40, 1)}, vouchers: 2, value: big.NewRat(20, 1), rule: RuleSenderVouchers},
+		{name: "up to the value limit", usage: Usage{Vouchers: 1, Value: big.NewRat(90, 1)}, vouchers: 1, value: big.NewRat(10, 1)},
+		{name: "over the value limit", usage: Usage{Vouchers: 1, Value: big.NewRat(90, 1)}, vouchers: 1, value: big.NewRat(201, 20), rule: RuleSenderValue},
+		{name: "vouchers checked first", usage: Usage{Vouchers: 5, Value: big.NewRat(100, 1)}, vouchers: 1, value: big.NewRat(10, 1), rule: RuleSenderVouchers},
+	}
+
+	for _, tt := range tests {
+		violation, ok := rules.CheckSender(tt.usage, tt.vouchers, tt.value)
+		if ok != (tt.rule != "") || violation.Rule != tt.rule {
+			t.Errorf("%s: CheckSender() = %q, %v, want %q", tt.name, violation.Rule, ok, tt.rule)
+		}
+		if ok && violation.Reason == "" {
+			t.Errorf("%s: violation without a reason", tt.name)
+		}
+	}
+
+	if !rules.SenderEnabled() || rules.VelocityEnabled() {
+		t.Errorf("SenderEnabled() = %v, VelocityEnabled() = %v, want true, false", rules.SenderEnabled(), rules.VelocityEnabled())
+	}
+}
+
+func TestCheckVelocity(t *testing.T) {
+	rules := Rules{MaxVouchers: 10, Window: time.Minute}
+
+	tests := []struct {
+		used     int
+		vouchers int
+		broken   bool
+	}{
+		{used: 0, vouchers: 10},
+		{used: 9, vouchers: 1},
+		{used: 9, vouchers: 2, broken: true},
+		{used: 10, vouchers: 1, broken: true},
+	}
+
+	for _, tt := range tests {
+		violation, ok := rules.CheckVelocity(Usage{Vouchers: tt.used}, tt.vouchers)
+		if ok != tt.broken || (ok && violation.Rule != RuleVelocity) {
+			t.Errorf("CheckVelocity(%d used, %d more) = %q, %v, want broken %v", tt.used, tt.vouchers, violation.Rule, ok, tt.broken)
+		}
+	}
+
+	if _, ok := (Rules{}).CheckVelocity(Usage{Vouchers: 1000}, 1000); ok {
+		t.Error("disabled velocity rule broken")
+	}
+}
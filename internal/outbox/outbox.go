@@ -0,0 +1,209 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"math/big"
+	"math/rand/v2"
+	"time"
+
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/store"
+	"github.com/grassrootseconomics/ethutils"
+	"github.com/lmittmann/w3/module/eth"
+)
+
+// stateTimeout bounds the state updates after an issue attempt. They run detached from the worker context so
+// that a shutdown mid-drain does not leave vouchers in processing.
+const stateTimeout = time.Second * 5
+
+// ErrRejected is returned by an IssueFunc when a voucher must never be issued. It is not retried.
+var ErrRejected = errors.New("outbox: voucher rejected")
+
+type (
+	IssueFunc func(context.Context, store.Voucher) error
+
+	WorkerOpts struct {
+		Store         store.Store
+		Issue         IssueFunc
+		ChainProvider *ethutils.Provider
+		// Confirmations is the number of blocks the chain head must be past a purchase before it is issued.
+		Confirmations uint64
+		Logg          *slog.Logger
+		PollInterval  time.Duration
+		BatchSize     int
+		MaxAttempts   int
+		BaseBackoff   time.Duration
+		MaxBackoff    time.Duration
+		// Refund queues an on-chain refund for vouchers that failed permanently.
+		Refund bool
+		// ProcessingLease is how long a voucher may stay in processing before it is taken up again.
+		ProcessingLease time.Duration
+	}
+
+	// Worker drains the voucher outbox, decoupling chain indexing from calls to external services.
+	Worker struct {
+		store         store.Store
+		issue         IssueFunc
+		chainProvider *ethutils.Provider
+		confirmations uint64
+		refund        bool
+		logg          *slog.Logger
+		pollInterval  time.Duration
+		batchSize     int
+		maxAttempts   int
+		baseBackoff   time.Duration
+		maxBackoff    time.Duration
+		lease         time.Duration
+	}
+)
+
+func New(o WorkerOpts) *Worker {
+	return &Worker{
+		store:         o.Store,
+		issue:         o.Issue,
+		chainProvider: o.ChainProvider,
+		confirmations: o.Confirmations,
+		refund:        o.Refund,
+		logg:          o.Logg,
+		pollInterval:  o.PollInterval,
+		batchSize:     o.BatchSize,
+		maxAttempts:   o.MaxAttempts,
+		baseBackoff:   o.BaseBackoff,
+		maxBackoff:    o.MaxBackoff,
+		lease:         o.ProcessingLease,
+	}
+}
+
+// Run polls the outbox until ctx is cancelled.
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			w.logg.Debug("outbox: worker stopped")
+			return
+		case <-ticker.C:
+			if err := w.drain(ctx); err != nil {
+				w.logg.Error("outbox: failed to drain vouchers", "error", err)
+			}
+		}
+	}
+}
+
+func (w *Worker) drain(ctx context.Context) error {
+	maxBlockNumber, err := w.confirmedBlock(ctx)
+	if err != nil {
+		return err
+	}
+
+	vouchers, err := w.store.FetchDueVouchers(ctx, w.batchSize, maxBlockNumber, w.lease)
+	if err != nil {
+		return err
+	}
+
+	for _, voucher := range vouchers {
+		w.process(ctx, voucher)
+	}
+
+	return nil
+}
+
+// confirmedBlock returns the highest block whose purchases have enough confirmations to be released.
+func (w *Worker) confirmedBlock(ctx context.Context) (uint64, error) {
+	if w.confirmations == 0 {
+		return math.MaxUint64, nil
+	}
+
+	var head *big.Int
+	if err := w.chainProvider.Client.CallCtx(
+		ctx,
+		eth.BlockNumber().Returns(&head),
+	); err != nil {
+		return 0, err
+	}
+
+	if head.Uint64() < w.confirmations {
+		return 0, nil
+	}
+	return head.Uint64() - w.confirmations, nil
+}
+
+func (w *Worker) process(ctx context.Context, voucher store.Voucher) {
+	err := w.issue(ctx, voucher)
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), stateTimeout)
+	defer cancel()
+
+	if err == nil {
+		if err := w.store.SetVoucherIssued(ctx, voucher.ID); err != nil {
+			// The vouchers exist on the provider at this point. The row is taken up again once its lease runs out,
+			// which only resends the notification as every item already carries its code.
+			w.logg.Error("outbox: failed to record issued voucher", "error", err, "id", voucher.ID)
+		}
+		return
+	}
+
+	if errors.Is(err, ErrRejected) {
+		if err := w.store.SetVoucherRejected(ctx, voucher.ID, err.Error()); err != nil {
+			w.logg.Error("outbox: failed to mark voucher as rejected", "error", err, "id", voucher.ID)
+		}
+		return
+	}
+
+	if voucher.Attempts >= w.maxAttempts {
+		w.logg.Error("outbox: voucher issuance failed permanently", "error", err, "id", voucher.ID, "tx_hash", voucher.TxHash, "attempts", voucher.Attempts)
+		refund := w.refundFor(voucher)
+		queued, err := w.store.SetVoucherFailed(ctx, voucher.ID, err.Error(), refund)
+		if err != nil {
+			w.logg.Error("outbox: failed to mark voucher as failed", "error", err, "id", voucher.ID)
+		} else if refund != nil && !queued {
+			w.logg.Warn("outbox: refund skipped, voucher partly issued or already refunded", "id", voucher.ID)
+		}
+		return
+	}
+
+	nextAttemptAt := time.Now().Add(w.backoff(voucher.Attempts))
+	w.logg.Warn("outbox: voucher issuance failed, retrying", "error", err, "id", voucher.ID, "attempts", voucher.Attempts, "next_attempt_at", nextAttemptAt)
+	if err := w.store.SetVoucherRetry(ctx, voucher.ID, nextAttemptAt, err.Error()); err != nil {
+		w.logg.Error("outbox: failed to schedule voucher retry", "error", err, "id", voucher.ID)
+	}
+}
+
+// backoff doubles the delay per attempt up to maxBackoff. Half of it is random, so that the vouchers failed by a
+// provider outage are not all retried at the same moment.
+func (w *Worker) backoff(attempts int) time.Duration {
+	delay := w.baseBackoff
+	for i := 1; i < attempts && delay < w.maxBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, w.maxBackoff)
+
+	if half := int64(delay / 2); half > 0 {
+		return time.Duration(half + rand.Int64N(half+1))
+	}
+	return delay
+}
+
+// refundFor returns the refund of a voucher that could not be issued, nil when refunds are disabled. The store
+// reverses the credit and partial payment entries of the purchase with it, and skips vouchers that were partly
+// issued since a full refund would pay back vouchers the sender already has.
+func (w *Worker) refundFor(voucher store.Voucher) *store.Refund {
+	if !w.refund {
+		return nil
+	}
+
+	return &store.Refund{
+		TenantID:        voucher.TenantID,
+		Source:          store.RefundSourceVoucher,
+		TxHash:          voucher.TxHash,
+		LogIndex:        voucher.LogIndex,
+		VaultAddress:    voucher.RecipientAddress,
+		SenderAddress:   voucher.SenderAddress,
+		ContractAddress: voucher.ContractAddress,
+		Value:           voucher.TransferValue,
+	}
+}
@@ -0,0 +1,10 @@
+package payment
+
+import (
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// QRCode renders a payment URI as a square PNG of size pixels.
+func QRCode(uri string, size int) ([]byte, error) {
+	return qrcode.Encode(uri, qrcode.Medium, size)
+}
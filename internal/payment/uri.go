@@ -0,0 +1,20 @@
+package payment
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/grassrootseconomics/ethutils"
+)
+
+// TransferURI returns an EIP-681 URI asking a wallet to transfer value base units of the ERC20 token to recipient
+// on the chain chainID.
+func TransferURI(chainID int64, tokenAddress string, recipientAddress string, value *big.Int) string {
+	return fmt.Sprintf(
+		"ethereum:%s@%d/transfer?address=%s&uint256=%s",
+		ethutils.ChecksumAddress(tokenAddress),
+		chainID,
+		ethutils.ChecksumAddress(recipientAddress),
+		value.String(),
+	)
+}
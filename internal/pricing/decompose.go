@@ -0,0 +1,123 @@
+package pricing
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+)
+
+const (
+	// DecomposeSingle buys the one tier whose range contains the amount.
+	DecomposeSingle = "single"
+	// DecomposeGreedy buys the most expensive affordable tier repeatedly until nothing else is affordable.
+	DecomposeGreedy = "greedy"
+	// DecomposeFixed buys as many vouchers of a chosen tier as the amount covers, at least Quantity and at most
+	// MaxVouchers, falling back to DecomposeSingle when the amount does not cover Quantity of them.
+	DecomposeFixed = "fixed"
+)
+
+// Decomposition is the policy used to turn a single payment into one or more vouchers.
+type Decomposition struct {
+	Policy      string
+	TierID      string
+	Quantity    int
+	MaxVouchers int
+}
+
+// Validate checks the policy against the tier table it will be applied to.
+func (d Decomposition) Validate(tiers *Tiers) error {
+	switch d.Policy {
+	case DecomposeSingle:
+		return nil
+	case DecomposeGreedy:
+		if d.MaxVouchers <= 0 {
+			return fmt.Errorf("pricing: greedy decomposition requires a positive max vouchers limit")
+		}
+		return nil
+	case DecomposeFixed:
+		if _, ok := tiers.Get(d.TierID); !ok {
+			return fmt.Errorf("pricing: fixed decomposition tier %q is not configured", d.TierID)
+		}
+		if d.Quantity <= 0 {
+			return fmt.Errorf("pricing: fixed decomposition requires a positive quantity")
+		}
+		return nil
+	default:
+		return fmt.Errorf("pricing: unknown decomposition policy %q", d.Policy)
+	}
+}
+
+// Decompose returns the tiers bought with amount under policy d. It returns false when nothing is affordable.
+func (t *Tiers) Decompose(amount *big.Rat, d Decomposition) ([]Tier, bool) {
+	switch d.Policy {
+	case DecomposeGreedy:
+		return t.decomposeGreedy(amount, d.MaxVouchers)
+	case DecomposeFixed:
+		if tier, ok := t.Get(d.TierID); ok && tier.Enabled {
+			if n := tier.Count(amount); n >= d.Quantity {
+				bought := make([]Tier, min(n, max(d.MaxVouchers, d.Quantity)))
+				for i := range bought {
+					bought[i] = tier
+				}
+				return bought, true
+			}
+		}
+	}
+
+	tier, ok := t.Match(amount)
+	if !ok {
+		return nil, false
+	}
+	return []Tier{tier}, true
+}
+
+func (t *Tiers) decomposeGreedy(amount *big.Rat, maxVouchers int) ([]Tier, bool) {
+	var (
+		bought    []Tier
+		remaining = new(big.Rat).Set(amount)
+	)
+
+	for len(bought) < maxVouchers {
+		tier, ok := t.largestAffordable(remaining)
+		if !ok {
+			break
+		}
+		bought = append(bought, tier)
+		remaining.Sub(remaining, tier.MinAmount)
+	}
+
+	return bought, len(bought) > 0
+}
+
+func (t *Tiers) largestAffordable(amount *big.Rat) (Tier, bool) {
+	for i := len(t.tiers) - 1; i >= 0; i-- {
+		if t.tiers[i].Enabled && amount.Cmp(t.tiers[i].MinAmount) >= 0 {
+			return t.tiers[i], true
+		}
+	}
+
+	return Tier{}, false
+}
+
+// Count returns how many vouchers of the tier amount pays for.
+func (t Tier) Count(amount *big.Rat) int {
+	if amount.Sign() <= 0 {
+		return 0
+	}
+
+	n := new(big.Int).Quo(new(big.Int).Mul(amount.Num(), t.MinAmount.Denom()), new(big.Int).Mul(amount.Denom(), t.MinAmount.Num()))
+	if !n.IsInt64() || n.Int64() > math.MaxInt {
+		return math.MaxInt
+	}
+	return int(n.Int64())
+}
+
+// Price returns the sum of the prices of the given tiers.
+func Price(tiers []Tier) *big.Rat {
+	total := new(big.Rat)
+	for _, tier := range tiers {
+		total.Add(total, tier.MinAmount)
+	}
+
+	return total
+}
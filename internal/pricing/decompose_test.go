@@ -0,0 +1,103 @@
+package pricing
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDecompose(t *testing.T) {
+	disabled := tier(t, "1d", "20", "50")
+	disabled.Enabled = false
+	tiers, err := NewTiers([]Tier{tier(t, "1h", "5", "20"), disabled, tier(t, "1w", "50", "")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		amount string
+		policy Decomposition
+		want   string
+	}{
+		{name: "single", amount: "120", policy: Decomposition{Policy: DecomposeSingle}, want: "1w"},
+		{name: "single below cheapest", amount: "4", policy: Decomposition{Policy: DecomposeSingle}, want: ""},
+		{name: "greedy", amount: "117", policy: Decomposition{Policy: DecomposeGreedy, MaxVouchers: 10}, want: "1w,1w,1h,1h,1h"},
+		{name: "greedy skips disabled", amount: "45", policy: Decomposition{Policy: DecomposeGreedy, MaxVouchers: 10}, want: "1h,1h,1h,1h,1h,1h,1h,1h,1h"},
+		{name: "greedy capped", amount: "500", policy: Decomposition{Policy: DecomposeGreedy, MaxVouchers: 3}, want: "1w,1w,1w"},
+		{name: "greedy below cheapest", amount: "4", policy: Decomposition{Policy: DecomposeGreedy, MaxVouchers: 3}, want: ""},
+		{name: "fixed", amount: "15", policy: Decomposition{Policy: DecomposeFixed, TierID: "1h", Quantity: 3}, want: "1h,1h,1h"},
+		{name: "fixed above quantity", amount: "27", policy: Decomposition{Policy: DecomposeFixed, TierID: "1h", Quantity: 3, MaxVouchers: 10}, want: "1h,1h,1h,1h,1h"},
+		{name: "fixed capped", amount: "100", policy: Decomposition{Policy: DecomposeFixed, TierID: "1h", Quantity: 3, MaxVouchers: 4}, want: "1h,1h,1h,1h"},
+		{name: "fixed without max vouchers", amount: "100", policy: Decomposition{Policy: DecomposeFixed, TierID: "1h", Quantity: 3}, want: "1h,1h,1h"},
+		{name: "fixed below quantity falls back to single", amount: "14", policy: Decomposition{Policy: DecomposeFixed, TierID: "1h", Quantity: 3}, want: "1h"},
+		{name: "fixed disabled tier falls back to single", amount: "60", policy: Decomposition{Policy: DecomposeFixed, TierID: "1d", Quantity: 2}, want: "1w"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bought, ok := tiers.Decompose(rat(t, tt.amount), tt.policy)
+
+			ids := make([]string, len(bought))
+			for i, tier := range bought {
+				ids[i] = tier.ID
+			}
+			if got := strings.Join(ids, ","); got != tt.want || ok != (tt.want != "") {
+				t.Errorf("Decompose(%s) = %q, %v, want %q", tt.amount, got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecompositionValidate(t *testing.T) {
+	tiers, err := NewTiers([]Tier{tier(t, "1h", "5", "")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		policy Decomposition
+		valid  bool
+	}{
+		{name: "single", policy: Decomposition{Policy: DecomposeSingle}, valid: true},
+		{name: "greedy", policy: Decomposition{Policy: DecomposeGreedy, MaxVouchers: 1}, valid: true},
+		{name: "greedy without max vouchers", policy: Decomposition{Policy: DecomposeGreedy}},
+		{name: "fixed", policy: Decomposition{Policy: DecomposeFixed, TierID: "1h", Quantity: 2}, valid: true},
+		{name: "fixed unknown tier", policy: Decomposition{Policy: DecomposeFixed, TierID: "1d", Quantity: 2}},
+		{name: "fixed without quantity", policy: Decomposition{Policy: DecomposeFixed, TierID: "1h"}},
+		{name: "unknown policy", policy: Decomposition{Policy: "random"}},
+	}
+
+	for _, tt := range tests {
+		if err := tt.policy.Validate(tiers); (err == nil) != tt.valid {
+			t.Errorf("%s: Validate() = %v, want valid %v", tt.name, err, tt.valid)
+		}
+	}
+}
+
+func TestCount(t *testing.T) {
+	tier := tier(t, "1h", "5/2", "")
+
+	tests := []struct {
+		amount string
+		want   int
+	}{
+		{amount: "0", want: 0},
+		{amount: "-5", want: 0},
+		{amount: "2.49", want: 0},
+		{amount: "2.5", want: 1},
+		{amount: "7.4", want: 2},
+		{amount: "7.5", want: 3},
+	}
+
+	for _, tt := range tests {
+		if got := tier.Count(rat(t, tt.amount)); got != tt.want {
+			t.Errorf("Count(%s) = %d, want %d", tt.amount, got, tt.want)
+		}
+	}
+
+	if got := Price(slices.Repeat([]Tier{tier}, 3)); got.Cmp(rat(t, "7.5")) != 0 {
+		t.Errorf("Price = %s, want 15/2", got.RatString())
+	}
+}
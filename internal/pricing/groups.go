@@ -0,0 +1,50 @@
+package pricing
+
+import (
+	"fmt"
+	"math/big"
+)
+
+type (
+	// PricingGroup gives the senders assigned to it a discount on every tier, as a fraction of the tier price.
+	PricingGroup struct {
+		ID       string
+		Discount *big.Rat
+	}
+
+	Groups struct {
+		groups map[string]PricingGroup
+	}
+)
+
+func NewGroups(groups []PricingGroup) (*Groups, error) {
+	registry := make(map[string]PricingGroup, len(groups))
+
+	for _, g := range groups {
+		if g.ID == "" {
+			return nil, fmt.Errorf("pricing: pricing group has no id")
+		}
+		if _, ok := registry[g.ID]; ok {
+			return nil, fmt.Errorf("pricing: duplicate pricing group %s", g.ID)
+		}
+		if g.Discount == nil || g.Discount.Sign() < 0 || g.Discount.Cmp(big.NewRat(1, 1)) >= 0 {
+			return nil, fmt.Errorf("pricing: pricing group %s discount must be at least 0 and below 1", g.ID)
+		}
+		registry[g.ID] = g
+	}
+
+	return &Groups{
+		groups: registry,
+	}, nil
+}
+
+func (g *Groups) Get(id string) (PricingGroup, bool) {
+	group, ok := g.groups[id]
+	return group, ok
+}
+
+// Apply converts a payment by a member of the group into tier price units, so that a payment of the discounted
+// price buys the tier.
+func (g PricingGroup) Apply(payment *big.Rat) *big.Rat {
+	return new(big.Rat).Quo(payment, new(big.Rat).Sub(big.NewRat(1, 1), g.Discount))
+}
@@ -0,0 +1,138 @@
+package pricing
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+)
+
+type (
+	// Tier maps a half-open payment range [MinAmount, MaxAmount), in token units, to a RadiusDesk profile.
+	// A nil MaxAmount marks the tier as unbounded. Tiers with SubscriptionDays are recurring plans, such as
+	// monthly home internet, whose subscription is extended every time the tier is bought. A tier that names its
+	// Profile gets its ProfilePK from the profile sync and may leave it unset.
+	Tier struct {
+		ID               string
+		Description      string
+		ProfilePK        int
+		MinAmount        *big.Rat
+		MaxAmount        *big.Rat
+		Enabled          bool
+		SubscriptionDays int
+		Profile          string
+	}
+
+	Tiers struct {
+		tiers []Tier
+	}
+)
+
+// NewTiers sorts and validates the tier table. Tiers must be contiguous: each tier's MaxAmount has to equal
+// the next tier's MinAmount and only the highest tier may be unbounded. Disabled tiers still take part in
+// validation so that switching one off never shifts its range onto a neighbour.
+func NewTiers(tiers []Tier) (*Tiers, error) {
+	sorted := make([]Tier, len(tiers))
+	copy(sorted, tiers)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].MinAmount.Cmp(sorted[j].MinAmount) < 0
+	})
+
+	if err := validate(sorted); err != nil {
+		return nil, err
+	}
+
+	return &Tiers{
+		tiers: sorted,
+	}, nil
+}
+
+func validate(tiers []Tier) error {
+	if len(tiers) == 0 {
+		return fmt.Errorf("pricing: no tiers configured")
+	}
+
+	seen := make(map[string]bool, len(tiers))
+	for i, t := range tiers {
+		if t.ID == "" {
+			return fmt.Errorf("pricing: tier %d has no id", i)
+		}
+		if seen[t.ID] {
+			return fmt.Errorf("pricing: duplicate tier id %s", t.ID)
+		}
+		seen[t.ID] = true
+
+		if t.ProfilePK < 0 || (t.ProfilePK == 0 && t.Profile == "") {
+			return fmt.Errorf("pricing: tier %s has invalid profile pk %d", t.ID, t.ProfilePK)
+		}
+		if t.SubscriptionDays < 0 {
+			return fmt.Errorf("pricing: tier %s has negative subscription days %d", t.ID, t.SubscriptionDays)
+		}
+		if t.MinAmount == nil || t.MinAmount.Sign() <= 0 {
+			return fmt.Errorf("pricing: tier %s must have a positive min amount", t.ID)
+		}
+		if t.MaxAmount != nil && t.MaxAmount.Cmp(t.MinAmount) <= 0 {
+			return fmt.Errorf("pricing: tier %s max amount %s is not above min amount %s", t.ID, t.MaxAmount.RatString(), t.MinAmount.RatString())
+		}
+
+		if i == len(tiers)-1 {
+			break
+		}
+
+		next := tiers[i+1]
+		if t.MaxAmount == nil {
+			return fmt.Errorf("pricing: tier %s is unbounded and overlaps tier %s", t.ID, next.ID)
+		}
+		switch t.MaxAmount.Cmp(next.MinAmount) {
+		case 1:
+			return fmt.Errorf("pricing: tier %s overlaps tier %s between %s and %s", t.ID, next.ID, next.MinAmount.RatString(), t.MaxAmount.RatString())
+		case -1:
+			return fmt.Errorf("pricing: gap between tier %s and tier %s from %s to %s", t.ID, next.ID, t.MaxAmount.RatString(), next.MinAmount.RatString())
+		}
+	}
+
+	return nil
+}
+
+// Match returns the enabled tier whose range contains amount, given in token units.
+func (t *Tiers) Match(amount *big.Rat) (Tier, bool) {
+	for _, tier := range t.tiers {
+		if !tier.Enabled {
+			continue
+		}
+
+		if amount.Cmp(tier.MinAmount) >= 0 && (tier.MaxAmount == nil || amount.Cmp(tier.MaxAmount) < 0) {
+			return tier, true
+		}
+	}
+
+	return Tier{}, false
+}
+
+// Get returns the tier with the given id, whether it is enabled or not.
+func (t *Tiers) Get(id string) (Tier, bool) {
+	for _, tier := range t.tiers {
+		if tier.ID == id {
+			return tier, true
+		}
+	}
+
+	return Tier{}, false
+}
+
+// Min returns the lowest price of any enabled tier, or nil when every tier is disabled.
+func (t *Tiers) Min() *big.Rat {
+	for _, tier := range t.tiers {
+		if tier.Enabled {
+			return tier.MinAmount
+		}
+	}
+
+	return nil
+}
+
+// All returns every configured tier, including disabled ones, ordered by MinAmount.
+func (t *Tiers) All() []Tier {
+	tiers := make([]Tier, len(t.tiers))
+	copy(tiers, t.tiers)
+	return tiers
+}
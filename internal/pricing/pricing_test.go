@@ -0,0 +1,168 @@
+package pricing
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func rat(t *testing.T, s string) *big.Rat {
+	t.Helper()
+
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		t.Fatalf("invalid rat %q", s)
+	}
+	return r
+}
+
+func tier(t *testing.T, id string, minAmount string, maxAmount string) Tier {
+	t.Helper()
+
+	tier := Tier{ID: id, ProfilePK: 1, MinAmount: rat(t, minAmount), Enabled: true}
+	if maxAmount != "" {
+		tier.MaxAmount = rat(t, maxAmount)
+	}
+	return tier
+}
+
+func TestNewTiers(t *testing.T) {
+	tests := []struct {
+		name  string
+		tiers func(t *testing.T) []Tier
+		err   string
+	}{
+		{
+			name: "contiguous out of order",
+			tiers: func(t *testing.T) []Tier {
+				return []Tier{tier(t, "1d", "20", ""), tier(t, "1h", "10", "20")}
+			},
+		},
+		{
+			name:  "empty",
+			tiers: func(*testing.T) []Tier { return nil },
+			err:   "no tiers configured",
+		},
+		{
+			name: "missing id",
+			tiers: func(t *testing.T) []Tier {
+				return []Tier{tier(t, "", "10", "")}
+			},
+			err: "has no id",
+		},
+		{
+			name: "duplicate id",
+			tiers: func(t *testing.T) []Tier {
+				return []Tier{tier(t, "1h", "10", "20"), tier(t, "1h", "20", "")}
+			},
+			err: "duplicate tier id",
+		},
+		{
+			name: "no profile",
+			tiers: func(t *testing.T) []Tier {
+				tier := tier(t, "1h", "10", "")
+				tier.ProfilePK = 0
+				return []Tier{tier}
+			},
+			err: "invalid profile pk",
+		},
+		{
+			name: "profile name only",
+			tiers: func(t *testing.T) []Tier {
+				tier := tier(t, "1h", "10", "")
+				tier.ProfilePK, tier.Profile = 0, "1 hour"
+				return []Tier{tier}
+			},
+		},
+		{
+			name: "zero min amount",
+			tiers: func(t *testing.T) []Tier {
+				return []Tier{tier(t, "1h", "0", "")}
+			},
+			err: "positive min amount",
+		},
+		{
+			name: "max not above min",
+			tiers: func(t *testing.T) []Tier {
+				return []Tier{tier(t, "1h", "10", "10")}
+			},
+			err: "is not above min amount",
+		},
+		{
+			name: "unbounded below another tier",
+			tiers: func(t *testing.T) []Tier {
+				return []Tier{tier(t, "1h", "10", ""), tier(t, "1d", "20", "")}
+			},
+			err: "is unbounded and overlaps",
+		},
+		{
+			name: "overlap",
+			tiers: func(t *testing.T) []Tier {
+				return []Tier{tier(t, "1h", "10", "25"), tier(t, "1d", "20", "")}
+			},
+			err: "overlaps tier 1d",
+		},
+		{
+			name: "gap",
+			tiers: func(t *testing.T) []Tier {
+				return []Tier{tier(t, "1h", "10", "15"), tier(t, "1d", "20", "")}
+			},
+			err: "gap between tier 1h and tier 1d",
+		},
+		{
+			name: "disabled tier keeps its range",
+			tiers: func(t *testing.T) []Tier {
+				disabled := tier(t, "1d", "20", "30")
+				disabled.Enabled = false
+				return []Tier{tier(t, "1h", "10", "20"), disabled, tier(t, "1w", "30", "")}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewTiers(tt.tiers(t))
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("unexpected error %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("error = %v, want it to contain %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	disabled := tier(t, "1d", "20", "30")
+	disabled.Enabled = false
+	tiers, err := NewTiers([]Tier{tier(t, "1h", "10", "20"), disabled, tier(t, "1w", "30", "")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		amount string
+		want   string
+	}{
+		{amount: "9.99", want: ""},
+		{amount: "10", want: "1h"},
+		{amount: "19.999999", want: "1h"},
+		{amount: "20", want: ""},
+		{amount: "30", want: "1w"},
+		{amount: "1000", want: "1w"},
+	}
+
+	for _, tt := range tests {
+		got, ok := tiers.Match(rat(t, tt.amount))
+		if ok != (tt.want != "") || got.ID != tt.want {
+			t.Errorf("Match(%s) = %q, %v, want %q", tt.amount, got.ID, ok, tt.want)
+		}
+	}
+
+	if lowest := tiers.Min(); lowest.Cmp(rat(t, "10")) != 0 {
+		t.Errorf("Min() = %s, want 10", lowest.RatString())
+	}
+}
@@ -0,0 +1,80 @@
+package pricing
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/grassrootseconomics/ethutils"
+)
+
+type (
+	// AcceptedToken is an ERC20 contract the vault accepts as payment. Tier amounts are multiplied by
+	// PriceMultiplier to get the price in this token.
+	AcceptedToken struct {
+		Address         string
+		PriceMultiplier *big.Rat
+	}
+
+	Tokens struct {
+		tokens map[string]AcceptedToken
+	}
+)
+
+func NewTokens(tokens []AcceptedToken) (*Tokens, error) {
+	registry := make(map[string]AcceptedToken, len(tokens))
+
+	for _, t := range tokens {
+		if !common.IsHexAddress(t.Address) {
+			return nil, fmt.Errorf("pricing: invalid accepted token address %q", t.Address)
+		}
+		if t.PriceMultiplier == nil || t.PriceMultiplier.Sign() <= 0 {
+			return nil, fmt.Errorf("pricing: accepted token %s must have a positive price multiplier", t.Address)
+		}
+
+		t.Address = ethutils.ChecksumAddress(t.Address)
+		if _, ok := registry[t.Address]; ok {
+			return nil, fmt.Errorf("pricing: duplicate accepted token %s", t.Address)
+		}
+		registry[t.Address] = t
+	}
+
+	return &Tokens{
+		tokens: registry,
+	}, nil
+}
+
+func (t *Tokens) Get(contractAddress string) (AcceptedToken, bool) {
+	token, ok := t.tokens[ethutils.ChecksumAddress(contractAddress)]
+	return token, ok
+}
+
+func (t *Tokens) All() []AcceptedToken {
+	tokens := make([]AcceptedToken, 0, len(t.tokens))
+	for _, token := range t.tokens {
+		tokens = append(tokens, token)
+	}
+	return tokens
+}
+
+func (t *Tokens) Size() int {
+	return len(t.tokens)
+}
+
+// Normalise converts a raw transfer value of this token into tier price units using the token decimals.
+// The arithmetic is exact, no rounding takes place.
+func (t AcceptedToken) Normalise(value *big.Int, decimals uint8) *big.Rat {
+	return new(big.Rat).Quo(ToUnits(value, decimals), t.PriceMultiplier)
+}
+
+// Value converts a tier price unit amount into a raw transfer value of this token, the inverse of Normalise.
+// Fractions of a base unit are rounded up so that the value always covers the amount.
+func (t AcceptedToken) Value(amount *big.Rat, decimals uint8) *big.Int {
+	scaled := new(big.Rat).Mul(new(big.Rat).Mul(amount, t.PriceMultiplier), new(big.Rat).SetInt(decimalsFactor(decimals)))
+
+	value, remainder := new(big.Int).QuoRem(scaled.Num(), scaled.Denom(), new(big.Int))
+	if remainder.Sign() > 0 {
+		value.Add(value, big.NewInt(1))
+	}
+	return value
+}
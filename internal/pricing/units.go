@@ -0,0 +1,37 @@
+package pricing
+
+import (
+	"math/big"
+	"strings"
+)
+
+// ToUnits converts a raw base unit value into token units.
+func ToUnits(value *big.Int, decimals uint8) *big.Rat {
+	return new(big.Rat).SetFrac(value, decimalsFactor(decimals))
+}
+
+// FormatUnits renders a raw base unit value as an exact decimal string in token units with trailing zeros
+// removed, e.g. 15500000 with 6 decimals is "15.5".
+func FormatUnits(value *big.Int, decimals uint8) string {
+	formatted := ToUnits(value, decimals).FloatString(int(decimals))
+	if strings.Contains(formatted, ".") {
+		formatted = strings.TrimRight(strings.TrimRight(formatted, "0"), ".")
+	}
+
+	return formatted
+}
+
+// AmountPrecision is the number of decimals kept when a price unit amount is persisted.
+const AmountPrecision = 18
+
+// FormatAmount renders a price unit amount as a decimal string, truncated towards zero to AmountPrecision
+// decimals so that stored credit never exceeds what was actually paid.
+func FormatAmount(amount *big.Rat) string {
+	factor := decimalsFactor(AmountPrecision)
+	scaled := new(big.Int).Quo(new(big.Int).Mul(amount.Num(), factor), amount.Denom())
+	return FormatUnits(scaled, AmountPrecision)
+}
+
+func decimalsFactor(decimals uint8) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+}
@@ -0,0 +1,84 @@
+package pricing
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestFormatUnits(t *testing.T) {
+	tests := []struct {
+		value    string
+		decimals uint8
+		want     string
+	}{
+		{value: "15500000", decimals: 6, want: "15.5"},
+		{value: "15000000", decimals: 6, want: "15"},
+		{value: "1", decimals: 18, want: "0.000000000000000001"},
+		{value: "0", decimals: 18, want: "0"},
+		{value: "12345", decimals: 0, want: "12345"},
+		{value: "100000000000000000000", decimals: 18, want: "100"},
+	}
+
+	for _, tt := range tests {
+		value, _ := new(big.Int).SetString(tt.value, 10)
+		if got := FormatUnits(value, tt.decimals); got != tt.want {
+			t.Errorf("FormatUnits(%s, %d) = %q, want %q", tt.value, tt.decimals, got, tt.want)
+		}
+	}
+}
+
+func TestFormatAmount(t *testing.T) {
+	tests := []struct {
+		amount string
+		want   string
+	}{
+		{amount: "10", want: "10"},
+		{amount: "31/2", want: "15.5"},
+		// Truncated, not rounded, so that stored credit never exceeds the payment.
+		{amount: "2/3", want: "0.666666666666666666"},
+		{amount: "1/1000000000000000000000", want: "0"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatAmount(rat(t, tt.amount)); got != tt.want {
+			t.Errorf("FormatAmount(%s) = %q, want %q", tt.amount, got, tt.want)
+		}
+	}
+}
+
+func TestNormalise(t *testing.T) {
+	tests := []struct {
+		name       string
+		multiplier string
+		value      string
+		decimals   uint8
+		want       string
+	}{
+		{name: "6 decimals", multiplier: "1", value: "15500000", decimals: 6, want: "31/2"},
+		{name: "18 decimals", multiplier: "1", value: "15500000000000000000", decimals: 18, want: "31/2"},
+		{name: "multiplier", multiplier: "2", value: "20000000", decimals: 6, want: "10"},
+		{name: "fractional multiplier", multiplier: "1/3", value: "1000000", decimals: 6, want: "3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := AcceptedToken{PriceMultiplier: rat(t, tt.multiplier)}
+			value, _ := new(big.Int).SetString(tt.value, 10)
+
+			got := token.Normalise(value, tt.decimals)
+			if got.Cmp(rat(t, tt.want)) != 0 {
+				t.Fatalf("Normalise = %s, want %s", got.RatString(), tt.want)
+			}
+			if back := token.Value(got, tt.decimals); back.Cmp(value) != 0 {
+				t.Errorf("Value(Normalise(%s)) = %s", tt.value, back)
+			}
+		})
+	}
+}
+
+func TestValueRoundsUp(t *testing.T) {
+	token := AcceptedToken{PriceMultiplier: rat(t, "1")}
+	if got := token.Value(rat(t, "1/3"), 6); got.Cmp(big.NewInt(333334)) != 0 {
+		t.Errorf("Value(1/3) = %s, want 333334", got)
+	}
+}
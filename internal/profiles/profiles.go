@@ -0,0 +1,137 @@
+package profiles
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/provider"
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/vault"
+	"github.com/grassrootseconomics/eth-indexer/v2/pkg/inethi"
+)
+
+var (
+	// ErrMissingProfile is returned by Sync when a tier points at a profile its site does not have.
+	ErrMissingProfile = errors.New("profiles: tier points at a missing RadiusDesk profile")
+	// ErrUnresolvedProfile is returned by Sync when a tier that only names its profile has no profile pk yet
+	// because the profiles of its site could not be fetched. Its vouchers would be issued with profile pk 0.
+	ErrUnresolvedProfile = errors.New("profiles: tier has no resolved RadiusDesk profile")
+)
+
+type (
+	WorkerOpts struct {
+		Vaults       *vault.Registry
+		Logg         *slog.Logger
+		SyncInterval time.Duration
+	}
+
+	// Worker resolves the tiers of every iNethi vault to the RadiusDesk profiles of its site. Tiers that name a
+	// profile are matched by name, the others are checked against their configured profile pk.
+	Worker struct {
+		vaults       *vault.Registry
+		logg         *slog.Logger
+		syncInterval time.Duration
+	}
+)
+
+func New(o WorkerOpts) *Worker {
+	return &Worker{
+		vaults:       o.Vaults,
+		logg:         o.Logg,
+		syncInterval: o.SyncInterval,
+	}
+}
+
+// Run syncs the profiles until ctx is cancelled.
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.syncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			w.logg.Debug("profiles: worker stopped")
+			return
+		case <-ticker.C:
+			if err := w.Sync(ctx); err != nil {
+				w.logg.Error("profiles: sync failed", "error", err)
+			}
+		}
+	}
+}
+
+// Sync pulls the profile list of every iNethi vault and updates its profile map. Vaults whose profiles can not
+// be fetched keep their previous mapping. The returned error wraps ErrMissingProfile when any tier could not be
+// resolved, and ErrUnresolvedProfile when a tier naming its profile was never resolved by an earlier sync.
+func (w *Worker) Sync(ctx context.Context) error {
+	var errs []error
+
+	for _, v := range w.vaults.All() {
+		p, ok := v.Provider.(*provider.Inethi)
+		if !ok {
+			continue
+		}
+
+		profiles, err := p.Profiles(ctx)
+		if err != nil {
+			metrics.GetOrCreateCounter("profile_sync_errors_total").Inc()
+			errs = append(errs, fmt.Errorf("vault %s: %w", v.Address, err))
+			for _, tier := range v.Tiers.All() {
+				if tier.Profile != "" && v.Profiles.PK(tier) == 0 {
+					errs = append(errs, fmt.Errorf("%w: vault %s tier %s names profile %q", ErrUnresolvedProfile, v.Address, tier.ID, tier.Profile))
+				}
+			}
+			continue
+		}
+
+		mappings, missing := match(v, profiles)
+		v.Profiles.Set(mappings, time.Now())
+		for _, mapping := range mappings {
+			if tier, ok := v.Tiers.Get(mapping.Tier); ok && !mapping.Missing && tier.ProfilePK != 0 && tier.ProfilePK != mapping.ProfilePK {
+				w.logg.Warn("profiles: tier profile pk differs from the configured one", "vault", v.Address, "tier", tier.ID, "configured", tier.ProfilePK, "synced", mapping.ProfilePK)
+			}
+		}
+		for _, mapping := range missing {
+			metrics.GetOrCreateCounter(fmt.Sprintf(`profile_sync_missing_total{vault=%q,tier=%q}`, v.Address, mapping.Tier)).Inc()
+			w.logg.Error("profiles: tier points at a missing RadiusDesk profile", "tenant", v.TenantID, "vault", v.Address, "tier", mapping.Tier, "profile", mapping.Profile, "profile_pk", mapping.ProfilePK)
+			errs = append(errs, fmt.Errorf("%w: vault %s tier %s", ErrMissingProfile, v.Address, mapping.Tier))
+		}
+		w.logg.Debug("profiles: synced", "vault", v.Address, "profiles", len(profiles), "missing", len(missing))
+	}
+
+	return errors.Join(errs...)
+}
+
+func match(v vault.Vault, profiles []inethi.Profile) ([]vault.ProfileMapping, []vault.ProfileMapping) {
+	var mappings, missing []vault.ProfileMapping
+
+	for _, tier := range v.Tiers.All() {
+		mapping := vault.ProfileMapping{
+			Tier:      tier.ID,
+			Profile:   tier.Profile,
+			ProfilePK: tier.ProfilePK,
+			Missing:   true,
+		}
+
+		for _, profile := range profiles {
+			if tier.Profile != "" && strings.EqualFold(strings.TrimSpace(profile.Name), strings.TrimSpace(tier.Profile)) ||
+				tier.Profile == "" && profile.ID == tier.ProfilePK {
+				mapping.Profile = profile.Name
+				mapping.ProfilePK = profile.ID
+				mapping.Missing = false
+				break
+			}
+		}
+
+		mappings = append(mappings, mapping)
+		if mapping.Missing {
+			missing = append(missing, mapping)
+		}
+	}
+
+	return mappings, missing
+}
@@ -0,0 +1,151 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type (
+	// HTTPOpts configures a provider reached over a plain HTTP/JSON API. Paths are relative to Endpoint,
+	// StatusPath may contain {code}. An empty RevokePath or StatusPath disables that operation.
+	HTTPOpts struct {
+		Endpoint   string
+		IssuePath  string
+		RevokePath string
+		StatusPath string
+		// Headers are sent with every request, e.g. an Authorization header.
+		Headers map[string]string
+		// CodeField and StateField name the response fields holding the voucher code and its state.
+		CodeField  string
+		StateField string
+		// Transport sends the requests, http.DefaultTransport when nil.
+		Transport http.RoundTripper
+	}
+
+	// HTTP issues vouchers through a generic HTTP/JSON API, e.g. a MikroTik hotspot gateway.
+	HTTP struct {
+		opts       HTTPOpts
+		httpClient *http.Client
+	}
+)
+
+func NewHTTP(o HTTPOpts) *HTTP {
+	if o.CodeField == "" {
+		o.CodeField = "code"
+	}
+	if o.StateField == "" {
+		o.StateField = "state"
+	}
+
+	p := &HTTP{
+		opts: o,
+		httpClient: &http.Client{
+			Transport: o.Transport,
+		},
+	}
+	// The transport bounds each attempt, a client timeout would cut its retries short.
+	if o.Transport == nil {
+		p.httpClient.Timeout = time.Second * 10
+	}
+
+	return p
+}
+
+// Issue posts the request as JSON to IssuePath and reads the voucher code from CodeField.
+func (p *HTTP) Issue(ctx context.Context, req IssueRequest) (string, error) {
+	var resp map[string]any
+	if err := p.request(ctx, http.MethodPost, p.opts.IssuePath, map[string]any{
+		"senderAddress":    req.SenderAddress,
+		"recipientAddress": req.RecipientAddress,
+		"amount":           req.Amount,
+		"token":            req.TokenSymbol,
+		"tier":             req.Tier,
+		"profile":          req.ProfilePK,
+	}, &resp); err != nil {
+		return "", err
+	}
+
+	code, ok := resp[p.opts.CodeField].(string)
+	if !ok || code == "" {
+		return "", fmt.Errorf("provider: issue response has no %q field", p.opts.CodeField)
+	}
+	return code, nil
+}
+
+func (p *HTTP) Revoke(ctx context.Context, code string) error {
+	if p.opts.RevokePath == "" {
+		return ErrUnsupported
+	}
+
+	var resp map[string]any
+	return p.request(ctx, http.MethodPost, p.opts.RevokePath, map[string]any{"code": code}, &resp)
+}
+
+func (p *HTTP) Status(ctx context.Context, code string) (Status, error) {
+	if p.opts.StatusPath == "" {
+		return Status{}, ErrUnsupported
+	}
+
+	var resp map[string]any
+	path := strings.ReplaceAll(p.opts.StatusPath, "{code}", url.PathEscape(code))
+	if err := p.request(ctx, http.MethodGet, path, nil, &resp); err != nil {
+		return Status{}, err
+	}
+
+	state, _ := resp[p.opts.StateField].(string)
+	return Status{
+		Code:  code,
+		State: state,
+	}, nil
+}
+
+func (p *HTTP) request(ctx context.Context, method string, path string, body any, target any) error {
+	var reqBody io.Reader
+	if body != nil {
+		var buf bytes.Buffer
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			return err
+		}
+		reqBody = &buf
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, p.opts.Endpoint+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range p.opts.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		b, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		return &StatusError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Body:       string(b),
+		}
+	}
+
+	return json.NewDecoder(resp.Body).Decode(target)
+}
@@ -0,0 +1,77 @@
+package provider
+
+import (
+	"context"
+	"errors"
+
+	"github.com/grassrootseconomics/eth-indexer/v2/pkg/inethi"
+)
+
+// Inethi issues vouchers on a RadiusDesk site through the iNethi API.
+type Inethi struct {
+	client *inethi.InethiClient
+	site   inethi.Site
+}
+
+func NewInethi(client *inethi.InethiClient, site inethi.Site) *Inethi {
+	return &Inethi{
+		client: client,
+		site:   site,
+	}
+}
+
+func (p *Inethi) Issue(ctx context.Context, req IssueRequest) (string, error) {
+	resp, err := p.client.GenerateVoucher(ctx, inethi.VoucherPayload{
+		Site:             p.site,
+		SenderAddress:    req.SenderAddress,
+		RecipientAddress: req.RecipientAddress,
+		Amount:           req.Amount,
+		TokenSymbol:      req.TokenSymbol,
+		CouponSize:       req.ProfilePK,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return resp.Voucher, nil
+}
+
+func (p *Inethi) Revoke(ctx context.Context, code string) error {
+	return notFound(p.client.RevokeVoucher(ctx, code))
+}
+
+func (p *Inethi) Status(ctx context.Context, code string) (Status, error) {
+	status, err := p.client.VoucherStatus(ctx, code)
+	if err != nil {
+		return Status{}, notFound(err)
+	}
+
+	return Status{
+		Code:     code,
+		State:    status.Status,
+		DataUsed: status.DataUsed,
+		TimeUsed: status.TimeUsed,
+	}, nil
+}
+
+// Delete removes a voucher from iNethi entirely, unlike Revoke.
+func (p *Inethi) Delete(ctx context.Context, code string) error {
+	return notFound(p.client.DeleteVoucher(ctx, code))
+}
+
+// Vouchers returns the vouchers iNethi generated for a sender, on any site.
+func (p *Inethi) Vouchers(ctx context.Context, senderAddress string) ([]inethi.Voucher, error) {
+	return p.client.ListVouchers(ctx, senderAddress)
+}
+
+// Profiles returns the RadiusDesk profiles of the provider's site.
+func (p *Inethi) Profiles(ctx context.Context) ([]inethi.Profile, error) {
+	return p.client.ListProfiles(ctx, p.site)
+}
+
+func notFound(err error) error {
+	if errors.Is(err, inethi.ErrNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,106 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/grassrootseconomics/eth-indexer/v2/pkg/inethi"
+	"github.com/grassrootseconomics/eth-indexer/v2/pkg/transport"
+)
+
+const (
+	StateActive  = "active"
+	StateUsed    = "used"
+	StateRevoked = "revoked"
+)
+
+var (
+	// ErrUnsupported is returned by providers that can not perform an operation.
+	ErrUnsupported = errors.New("provider: operation not supported")
+	// ErrNotFound is returned for a voucher code the provider does not know.
+	ErrNotFound = errors.New("provider: voucher not found")
+)
+
+type (
+	// VoucherProvider creates and manages vouchers on the network access system of a site, e.g. RadiusDesk or
+	// FreeRADIUS.
+	VoucherProvider interface {
+		Issue(context.Context, IssueRequest) (string, error)
+		Revoke(context.Context, string) error
+		Status(context.Context, string) (Status, error)
+	}
+
+	// IssueRequest describes the voucher a payment bought. ProfilePK is the RadiusDesk profile of the tier,
+	// other providers identify the voucher kind by Tier.
+	IssueRequest struct {
+		SenderAddress    string
+		RecipientAddress string
+		Amount           string
+		TokenSymbol      string
+		Tier             string
+		ProfilePK        int
+	}
+
+	// Status is the state of an issued voucher, usually one of the State constants. Providers that track usage
+	// report DataUsed in bytes and TimeUsed in seconds.
+	Status struct {
+		Code     string `json:"code"`
+		State    string `json:"state"`
+		DataUsed int64  `json:"dataUsed,omitempty"`
+		TimeUsed int64  `json:"timeUsed,omitempty"`
+	}
+
+	// StatusError is returned by the HTTP provider for a response with an error status other than 404.
+	StatusError struct {
+		StatusCode int
+		Status     string
+		Body       string
+	}
+
+	// None stands in for the provider of a vault that issues every voucher from its pool, so that no provider
+	// settings are needed for it. Every operation returns ErrUnsupported.
+	None struct{}
+)
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("provider server error: code=%s: response_body=%s", e.Status, e.Body)
+}
+
+func (None) Issue(context.Context, IssueRequest) (string, error) {
+	return "", ErrUnsupported
+}
+
+func (None) Revoke(context.Context, string) error {
+	return ErrUnsupported
+}
+
+func (None) Status(context.Context, string) (Status, error) {
+	return Status{}, ErrUnsupported
+}
+
+// Unavailable reports whether a provider error means the provider could not serve the request at all: a failed
+// connect, an open circuit breaker or a 5xx response. A cancelled request or a request the provider rejected is not,
+// another voucher source would not fix it. Neither is a timeout, the provider may still have issued the voucher, so
+// the request is retried rather than served from another source.
+func Unavailable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, transport.ErrCircuitOpen) {
+		return true
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500
+	}
+	var inethiErr *inethi.StatusError
+	if errors.As(err, &inethiErr) {
+		return inethiErr.StatusCode >= 500
+	}
+
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial" && !opErr.Timeout()
+}
@@ -0,0 +1,146 @@
+package provider
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/knadh/goyesql/v2"
+)
+
+const (
+	codeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	codeLength   = 8
+)
+
+type (
+	// RadiusOpts configures a provider writing directly into the Postgres schema of a FreeRADIUS server.
+	RadiusOpts struct {
+		DSN string
+		// QueriesPath is the goyesql file holding the radius queries.
+		QueriesPath string
+		// Groups maps a tier to the radusergroup group that carries its session limits. A tier without a
+		// mapping uses its ID as group name.
+		Groups map[string]string
+	}
+
+	// Radius issues vouchers as FreeRADIUS users: the voucher code is both the username and the
+	// Cleartext-Password in radcheck, and the tier decides the group in radusergroup.
+	Radius struct {
+		db      *pgxpool.Pool
+		groups  map[string]string
+		queries radiusQueries
+	}
+
+	radiusQueries struct {
+		InsertPassword string `query:"insert-password"`
+		InsertGroup    string `query:"insert-group"`
+		InsertReject   string `query:"insert-reject"`
+		GetStatus      string `query:"get-status"`
+	}
+)
+
+func NewRadius(o RadiusOpts) (*Radius, error) {
+	parsedQueries, err := goyesql.ParseFile(o.QueriesPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var queries radiusQueries
+	if err := goyesql.ScanToStruct(&queries, parsedQueries, nil); err != nil {
+		return nil, fmt.Errorf("failed to scan radius queries %v", err)
+	}
+
+	dbPool, err := pgxpool.New(context.Background(), o.DSN)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Radius{
+		db:      dbPool,
+		groups:  o.Groups,
+		queries: queries,
+	}, nil
+}
+
+func (p *Radius) Issue(ctx context.Context, req IssueRequest) (string, error) {
+	code, err := newCode()
+	if err != nil {
+		return "", err
+	}
+
+	group := req.Tier
+	if g, ok := p.groups[req.Tier]; ok {
+		group = g
+	}
+
+	err = pgx.BeginFunc(ctx, p.db, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, p.queries.InsertPassword, code); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, p.queries.InsertGroup, code, group)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return code, nil
+}
+
+// Revoke rejects further logins of a voucher. Its rows are kept so that Status can still report it.
+func (p *Radius) Revoke(ctx context.Context, code string) error {
+	if _, err := p.Status(ctx, code); err != nil {
+		return err
+	}
+
+	_, err := p.db.Exec(ctx, p.queries.InsertReject, code)
+	return err
+}
+
+// Status reports a voucher as revoked once rejected, as used once it has an accounting session and as active
+// otherwise.
+func (p *Radius) Status(ctx context.Context, code string) (Status, error) {
+	var exists, revoked, used bool
+	err := p.db.QueryRow(ctx, p.queries.GetStatus, code).Scan(&exists, &revoked, &used)
+	if err != nil {
+		return Status{}, err
+	}
+	if !exists {
+		return Status{}, ErrNotFound
+	}
+
+	status := Status{
+		Code:  code,
+		State: StateActive,
+	}
+	switch {
+	case revoked:
+		status.State = StateRevoked
+	case used:
+		status.State = StateUsed
+	}
+	return status, nil
+}
+
+// Close closes the connection pool to the FreeRADIUS database.
+func (p *Radius) Close() {
+	p.db.Close()
+}
+
+// newCode returns a random voucher code without easily confused characters.
+func newCode() (string, error) {
+	alphabetSize := big.NewInt(int64(len(codeAlphabet)))
+	code := make([]byte, codeLength)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, alphabetSize)
+		if err != nil {
+			return "", fmt.Errorf("provider: voucher code: %w", err)
+		}
+		code[i] = codeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
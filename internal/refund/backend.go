@@ -0,0 +1,83 @@
+package refund
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/grassrootseconomics/ethutils"
+	"github.com/lmittmann/w3/module/eth"
+	"github.com/lmittmann/w3/w3types"
+)
+
+// Backend is the chain access a refund needs. Its methods match go-ethereum's ethclient.
+type Backend interface {
+	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+	NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error)
+	SuggestGasTipCap(ctx context.Context) (*big.Int, error)
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	EstimateGas(ctx context.Context, msg ethereum.CallMsg) (uint64, error)
+	SendTransaction(ctx context.Context, tx *types.Transaction) error
+	TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
+}
+
+// pendingBlock asks the node for the pending state, which counts transactions still in its pool.
+var pendingBlock = big.NewInt(-1)
+
+type providerBackend struct {
+	provider *ethutils.Provider
+}
+
+// NewProviderBackend returns a Backend that talks to the node through provider.
+func NewProviderBackend(provider *ethutils.Provider) Backend {
+	return &providerBackend{
+		provider: provider,
+	}
+}
+
+func (b *providerBackend) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	var nonce uint64
+	err := b.provider.Client.CallCtx(ctx, eth.Nonce(account, pendingBlock).Returns(&nonce))
+	return nonce, err
+}
+
+func (b *providerBackend) NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error) {
+	var nonce uint64
+	err := b.provider.Client.CallCtx(ctx, eth.Nonce(account, blockNumber).Returns(&nonce))
+	return nonce, err
+}
+
+func (b *providerBackend) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	var tip *big.Int
+	err := b.provider.Client.CallCtx(ctx, eth.GasTipCap().Returns(&tip))
+	return tip, err
+}
+
+func (b *providerBackend) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	var header *types.Header
+	err := b.provider.Client.CallCtx(ctx, eth.HeaderByNumber(number).Returns(&header))
+	return header, err
+}
+
+func (b *providerBackend) EstimateGas(ctx context.Context, msg ethereum.CallMsg) (uint64, error) {
+	var gas uint64
+	err := b.provider.Client.CallCtx(ctx, eth.EstimateGas(&w3types.Message{
+		From:  msg.From,
+		To:    msg.To,
+		Input: msg.Data,
+	}, nil).Returns(&gas))
+	return gas, err
+}
+
+func (b *providerBackend) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	var hash common.Hash
+	return b.provider.Client.CallCtx(ctx, eth.SendTx(tx).Returns(&hash))
+}
+
+func (b *providerBackend) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	var receipt *types.Receipt
+	err := b.provider.Client.CallCtx(ctx, eth.TxReceipt(txHash).Returns(&receipt))
+	return receipt, err
+}
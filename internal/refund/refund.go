@@ -0,0 +1,313 @@
+package refund
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/store"
+	"github.com/lmittmann/w3"
+)
+
+var (
+	transferFunc  = w3.MustNewFunc("transfer(address,uint256)", "bool")
+	transferEvent = w3.MustNewEvent("Transfer(address indexed from, address indexed to, uint256 value)")
+)
+
+type (
+	WorkerOpts struct {
+		Store        store.Store
+		Backend      Backend
+		Signer       Signer
+		Logg         *slog.Logger
+		PollInterval time.Duration
+		BatchSize    int
+		MaxAttempts  int
+	}
+
+	// Worker sends queued refunds from the vault back to the sender and follows them until they are mined.
+	Worker struct {
+		store        store.Store
+		backend      Backend
+		signer       Signer
+		logg         *slog.Logger
+		pollInterval time.Duration
+		batchSize    int
+		maxAttempts  int
+	}
+)
+
+func New(o WorkerOpts) *Worker {
+	return &Worker{
+		store:        o.Store,
+		backend:      o.Backend,
+		signer:       o.Signer,
+		logg:         o.Logg,
+		pollInterval: o.PollInterval,
+		batchSize:    o.BatchSize,
+		maxAttempts:  o.MaxAttempts,
+	}
+}
+
+// Run sends and confirms refunds until ctx is cancelled.
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			w.logg.Debug("refund: worker stopped")
+			return
+		case <-ticker.C:
+			if err := w.confirm(ctx); err != nil {
+				w.logg.Error("refund: failed to confirm refunds", "error", err)
+			}
+			if err := w.send(ctx); err != nil {
+				w.logg.Error("refund: failed to send refunds", "error", err)
+			}
+		}
+	}
+}
+
+func (w *Worker) send(ctx context.Context) error {
+	refunds, err := w.store.FetchRefunds(ctx, store.RefundStatusPending, w.batchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, refund := range refunds {
+		w.sendRefund(ctx, refund)
+	}
+
+	return nil
+}
+
+// sendRefund signs and broadcasts a pending refund. The signed transaction is recorded before it is broadcast and
+// a failed broadcast is left to confirmRefund, so that a refund is never signed twice with different nonces.
+func (w *Worker) sendRefund(ctx context.Context, refund store.Refund) {
+	reason, err := w.verifyPayment(ctx, refund)
+	if err != nil {
+		w.retry(ctx, refund, err)
+		return
+	}
+	if reason != "" {
+		w.fail(ctx, refund, "payment not verified on chain: "+reason)
+		return
+	}
+
+	tx, err := w.BuildTx(ctx, refund)
+	if err != nil {
+		w.retry(ctx, refund, err)
+		return
+	}
+
+	rawTx, err := tx.MarshalBinary()
+	if err != nil {
+		w.retry(ctx, refund, err)
+		return
+	}
+
+	if err := w.store.SetRefundSent(ctx, refund.ID, tx.Hash().Hex(), rawTx); err != nil {
+		w.logg.Error("refund: failed to record refund transaction", "error", err, "id", refund.ID)
+		return
+	}
+
+	if err := w.backend.SendTransaction(ctx, tx); err != nil {
+		w.retry(ctx, refund, err)
+		return
+	}
+	w.logg.Info("refund: refund sent", "id", refund.ID, "sender", refund.SenderAddress, "value", refund.Value, "refund_tx_hash", tx.Hash().Hex())
+}
+
+// verifyPayment checks the refunded payment against its receipt, so that the vault only ever pays back a transfer
+// it received. It returns why the payment does not match, or an empty reason when the log at the payment's index
+// is exactly the recorded transfer.
+func (w *Worker) verifyPayment(ctx context.Context, refund store.Refund) (string, error) {
+	receipt, err := w.backend.TransactionReceipt(ctx, common.HexToHash(refund.TxHash))
+	if err != nil {
+		return "", err
+	}
+	if receipt == nil {
+		return "payment transaction not found", nil
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return "payment transaction reverted", nil
+	}
+
+	value, ok := new(big.Int).SetString(refund.Value, 10)
+	if !ok {
+		return fmt.Sprintf("invalid value %s", refund.Value), nil
+	}
+
+	for _, log := range receipt.Logs {
+		if log.Index != refund.LogIndex {
+			continue
+		}
+		if log.Address != common.HexToAddress(refund.ContractAddress) || len(log.Topics) != 3 || log.Topics[0] != transferEvent.Topic0 {
+			break
+		}
+
+		var (
+			from, to common.Address
+			logValue big.Int
+		)
+		if err := transferEvent.DecodeArgs(log, &from, &to, &logValue); err != nil {
+			return fmt.Sprintf("undecodable transfer log: %v", err), nil
+		}
+		if from != common.HexToAddress(refund.SenderAddress) || to != common.HexToAddress(refund.VaultAddress) || logValue.Cmp(value) != 0 {
+			return "transfer log does not match the payment", nil
+		}
+		return "", nil
+	}
+
+	return fmt.Sprintf("no transfer log at index %d", refund.LogIndex), nil
+}
+
+// BuildTx builds and signs the ERC20 transfer of a refund from the vault back to the sender.
+func (w *Worker) BuildTx(ctx context.Context, refund store.Refund) (*types.Transaction, error) {
+	var (
+		vaultAddress    = common.HexToAddress(refund.VaultAddress)
+		contractAddress = common.HexToAddress(refund.ContractAddress)
+	)
+	if !w.signer.Has(vaultAddress) {
+		return nil, fmt.Errorf("refund: no key for vault %s", refund.VaultAddress)
+	}
+
+	value, ok := new(big.Int).SetString(refund.Value, 10)
+	if !ok {
+		return nil, fmt.Errorf("refund: invalid value %s", refund.Value)
+	}
+
+	input, err := transferFunc.EncodeArgs(common.HexToAddress(refund.SenderAddress), value)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := w.backend.PendingNonceAt(ctx, vaultAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	gasTipCap, err := w.backend.SuggestGasTipCap(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	head, err := w.backend.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	gasFeeCap := new(big.Int).Add(gasTipCap, new(big.Int).Mul(head.BaseFee, big.NewInt(2)))
+
+	gasLimit, err := w.backend.EstimateGas(ctx, ethereum.CallMsg{
+		From: vaultAddress,
+		To:   &contractAddress,
+		Data: input,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return w.signer.SignTx(vaultAddress, types.NewTx(&types.DynamicFeeTx{
+		Nonce:     nonce,
+		To:        &contractAddress,
+		Data:      input,
+		Gas:       gasLimit,
+		GasFeeCap: gasFeeCap,
+		GasTipCap: gasTipCap,
+	}))
+}
+
+func (w *Worker) confirm(ctx context.Context) error {
+	refunds, err := w.store.FetchRefunds(ctx, store.RefundStatusSent, w.batchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, refund := range refunds {
+		w.confirmRefund(ctx, refund)
+	}
+
+	return nil
+}
+
+// confirmRefund checks a sent refund for its receipt. Without a receipt the signed transaction is broadcast
+// again, in case the node dropped it or the indexer stopped before sending it.
+func (w *Worker) confirmRefund(ctx context.Context, refund store.Refund) {
+	receipt, err := w.backend.TransactionReceipt(ctx, common.HexToHash(refund.RefundTxHash))
+	if err != nil || receipt == nil {
+		var tx types.Transaction
+		if err := tx.UnmarshalBinary(refund.RawTx); err != nil {
+			w.logg.Error("refund: invalid recorded refund transaction", "error", err, "id", refund.ID)
+			return
+		}
+		if err := w.backend.SendTransaction(ctx, &tx); err != nil {
+			w.rebroadcastFailed(ctx, refund, &tx, err)
+			return
+		}
+		w.logg.Debug("refund: refund transaction not mined yet", "id", refund.ID, "refund_tx_hash", refund.RefundTxHash)
+		return
+	}
+
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		w.fail(ctx, refund, "refund transaction reverted on chain")
+		return
+	}
+
+	metrics.GetOrCreateCounter("refunds_confirmed_total").Inc()
+	w.logg.Info("refund: refund confirmed", "id", refund.ID, "sender", refund.SenderAddress, "value", refund.Value, "refund_tx_hash", refund.RefundTxHash)
+	if err := w.store.SetRefundConfirmed(ctx, refund.ID); err != nil {
+		w.logg.Error("refund: failed to mark refund as confirmed", "error", err, "id", refund.ID)
+	}
+}
+
+// rebroadcastFailed decides what a failed rebroadcast means. The node rejects a transaction it already holds
+// ("already known") or one whose nonce was mined ("nonce too low"), so the error alone says nothing about the
+// refund. Only once the vault's nonce has moved past the refund without a receipt for it is the attempt counted.
+func (w *Worker) rebroadcastFailed(ctx context.Context, refund store.Refund, tx *types.Transaction, sendErr error) {
+	nonce, err := w.backend.NonceAt(ctx, common.HexToAddress(refund.VaultAddress), nil)
+	if err != nil {
+		w.logg.Warn("refund: failed to check vault nonce", "error", err, "id", refund.ID)
+		return
+	}
+	if nonce <= tx.Nonce() {
+		w.logg.Debug("refund: refund transaction still pending", "error", sendErr, "id", refund.ID, "refund_tx_hash", refund.RefundTxHash)
+		return
+	}
+
+	receipt, err := w.backend.TransactionReceipt(ctx, tx.Hash())
+	if err == nil && receipt != nil {
+		// Mined since the first lookup, the next round confirms it.
+		return
+	}
+	w.retry(ctx, refund, fmt.Errorf("nonce %d used by another transaction: %w", tx.Nonce(), sendErr))
+}
+
+// retry counts a failed attempt. A refund that keeps failing is marked as failed for manual review, a sent one may
+// still be mined and has to be checked on chain before anything else is done with it.
+func (w *Worker) retry(ctx context.Context, refund store.Refund, err error) {
+	if refund.Attempts+1 >= w.maxAttempts {
+		w.fail(ctx, refund, err.Error())
+		return
+	}
+
+	w.logg.Warn("refund: refund failed, retrying", "error", err, "id", refund.ID, "attempts", refund.Attempts)
+	if err := w.store.SetRefundRetry(ctx, refund.ID, err.Error()); err != nil {
+		w.logg.Error("refund: failed to schedule refund retry", "error", err, "id", refund.ID)
+	}
+}
+
+func (w *Worker) fail(ctx context.Context, refund store.Refund, reason string) {
+	metrics.GetOrCreateCounter("refunds_failed_total").Inc()
+	w.logg.Error("refund: refund failed permanently", "reason", reason, "id", refund.ID, "tx_hash", refund.TxHash, "sender", refund.SenderAddress, "value", refund.Value)
+	if err := w.store.SetRefundFailed(ctx, refund.ID, reason); err != nil {
+		w.logg.Error("refund: failed to mark refund as failed", "error", err, "id", refund.ID)
+	}
+}
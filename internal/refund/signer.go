@@ -0,0 +1,48 @@
+package refund
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+type (
+	// Signer signs refund transactions on behalf of a vault.
+	Signer interface {
+		// Has reports whether the signer holds the key of address.
+		Has(address common.Address) bool
+		SignTx(from common.Address, tx *types.Transaction) (*types.Transaction, error)
+	}
+
+	// KeystoreSigner signs with the accounts of a go-ethereum keystore directory sharing one passphrase.
+	KeystoreSigner struct {
+		keystore   *keystore.KeyStore
+		passphrase string
+		chainID    *big.Int
+	}
+)
+
+func NewKeystoreSigner(keystoreDir string, passphrase string, chainID int64) *KeystoreSigner {
+	return &KeystoreSigner{
+		keystore:   keystore.NewKeyStore(keystoreDir, keystore.StandardScryptN, keystore.StandardScryptP),
+		passphrase: passphrase,
+		chainID:    big.NewInt(chainID),
+	}
+}
+
+func (s *KeystoreSigner) Has(address common.Address) bool {
+	return s.keystore.HasAddress(address)
+}
+
+func (s *KeystoreSigner) SignTx(from common.Address, tx *types.Transaction) (*types.Transaction, error) {
+	account, err := s.keystore.Find(accounts.Account{Address: from})
+	if err != nil {
+		return nil, fmt.Errorf("refund: no keystore account for %s: %w", from.Hex(), err)
+	}
+
+	return s.keystore.SignTxWithPassphrase(account, s.passphrase, tx, s.chainID)
+}
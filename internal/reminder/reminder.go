@@ -0,0 +1,96 @@
+package reminder
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/store"
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/vault"
+	"github.com/grassrootseconomics/eth-indexer/v2/pkg/notify"
+)
+
+type (
+	WorkerOpts struct {
+		Store  store.Store
+		Vaults *vault.Registry
+		Logg   *slog.Logger
+		// Lead is how long before a subscription ends the sender is reminded to renew.
+		Lead          time.Duration
+		CheckInterval time.Duration
+	}
+
+	// Worker sends a single renewal reminder for every subscription that is about to expire.
+	Worker struct {
+		store         store.Store
+		vaults        *vault.Registry
+		logg          *slog.Logger
+		lead          time.Duration
+		checkInterval time.Duration
+	}
+)
+
+func New(o WorkerOpts) *Worker {
+	return &Worker{
+		store:         o.Store,
+		vaults:        o.Vaults,
+		logg:          o.Logg,
+		lead:          o.Lead,
+		checkInterval: o.CheckInterval,
+	}
+}
+
+// Run checks for expiring subscriptions until ctx is cancelled.
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			w.logg.Debug("reminder: worker stopped")
+			return
+		case <-ticker.C:
+			if err := w.remind(ctx); err != nil {
+				w.logg.Error("reminder: failed to send renewal reminders", "error", err)
+			}
+		}
+	}
+}
+
+func (w *Worker) remind(ctx context.Context) error {
+	subscriptions, err := w.store.GetExpiringSubscriptions(ctx, time.Now().Add(w.lead))
+	if err != nil {
+		return err
+	}
+
+	for _, subscription := range subscriptions {
+		w.send(ctx, subscription)
+	}
+
+	return nil
+}
+
+func (w *Worker) send(ctx context.Context, subscription store.Subscription) {
+	v, ok := w.vaults.Get(subscription.VaultAddress)
+	if !ok {
+		w.logg.Warn("reminder: vault no longer configured, skipping subscription", "id", subscription.ID, "vault", subscription.VaultAddress)
+		return
+	}
+
+	resp, err := v.NotifyClient.SendReminder(ctx, notify.ReminderPayload{
+		SenderAddress: subscription.SenderAddress,
+		Size:          subscription.TierDescription,
+		ExpiresAt:     subscription.EndsAt,
+	})
+	if err != nil {
+		// Not marked as reminded, the next check tries again.
+		w.logg.Error("reminder: failed to send renewal reminder", "error", err, "id", subscription.ID, "sender", subscription.SenderAddress)
+		return
+	}
+	w.logg.Debug("reminder: renewal reminder sent", "success", resp.Success, "message", resp.Message, "sender", subscription.SenderAddress, "tier", subscription.Tier, "ends_at", subscription.EndsAt)
+
+	if err := w.store.SetSubscriptionReminded(ctx, subscription.ID); err != nil {
+		w.logg.Error("reminder: failed to record renewal reminder", "error", err, "id", subscription.ID)
+	}
+}
@@ -2,13 +2,17 @@ package store
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"math"
 	"os"
 	"time"
 
 	"github.com/grassrootseconomics/eth-tracker/pkg/event"
+	"github.com/grassrootseconomics/ethutils"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/jackc/tern/v2/migrate"
 	"github.com/knadh/goyesql/v2"
@@ -37,8 +41,72 @@ type (
 		// InsertPoolSwap        string `query:"insert-pool-swap"`
 		// InsertPoolDeposit     string `query:"insert-pool-deposit"`
 		// InsertOwnershipChange string `query:"insert-ownership-change"`
-		InsertToken    string `query:"insert-token"`
-		GetTokenSymbol string `query:"get-token-symbol"`
+		InsertToken          string `query:"insert-token"`
+		GetToken             string `query:"get-token"`
+		InsertVoucher        string `query:"insert-voucher"`
+		InsertVoucherItem    string `query:"insert-voucher-item"`
+		FetchDueVouchers     string `query:"fetch-due-vouchers"`
+		GetVoucherItems      string `query:"get-voucher-items"`
+		SetVoucherItemIssued string `query:"set-voucher-item-issued"`
+		SetVoucherIssued     string `query:"set-voucher-issued"`
+		SetVoucherRetry      string `query:"set-voucher-retry"`
+		SetVoucherFailed     string `query:"set-voucher-failed"`
+		SetVoucherRejected   string `query:"set-voucher-rejected"`
+
+		ExtendSubscription       string `query:"extend-subscription"`
+		GetExpiringSubscriptions string `query:"get-expiring-subscriptions"`
+		SetSubscriptionReminded  string `query:"set-subscription-reminded"`
+
+		GetSenderUsage string `query:"get-sender-usage"`
+		GetTenantUsage string `query:"get-tenant-usage"`
+		InsertReview   string `query:"insert-review"`
+
+		ListReviews        string `query:"list-reviews"`
+		GetReview          string `query:"get-review"`
+		ResolveReview      string `query:"resolve-review"`
+		ReleaseHeldVoucher string `query:"release-held-voucher"`
+		RejectHeldVoucher  string `query:"reject-held-voucher"`
+
+		GetAddress    string `query:"get-address"`
+		ListAddresses string `query:"list-addresses"`
+		UpsertAddress string `query:"upsert-address"`
+		DeleteAddress string `query:"delete-address"`
+
+		InsertUnmatchedPayment  string `query:"insert-unmatched-payment"`
+		ListUnmatchedPayments   string `query:"list-unmatched-payments"`
+		GetUnmatchedPayment     string `query:"get-unmatched-payment"`
+		ResolveUnmatchedPayment string `query:"resolve-unmatched-payment"`
+
+		InsertRefund       string `query:"insert-refund"`
+		FetchRefunds       string `query:"fetch-refunds"`
+		SetRefundSent      string `query:"set-refund-sent"`
+		SetRefundRetry     string `query:"set-refund-retry"`
+		SetRefundConfirmed string `query:"set-refund-confirmed"`
+		SetRefundFailed    string `query:"set-refund-failed"`
+
+		ExpireOrders    string `query:"expire-orders"`
+		InsertOrder     string `query:"insert-order"`
+		GetOrder        string `query:"get-order"`
+		GetOpenOrder    string `query:"get-open-order"`
+		SetOrderPaid    string `query:"set-order-paid"`
+		CountOpenOrders string `query:"count-open-orders"`
+
+		InsertPoolCode string `query:"insert-pool-code"`
+		GetPoolClaim   string `query:"get-pool-claim"`
+		ClaimPoolCode  string `query:"claim-pool-code"`
+		CountPoolCodes string `query:"count-pool-codes"`
+		ListPoolStock  string `query:"list-pool-stock"`
+
+		InsertUnacceptedPayment string `query:"insert-unaccepted-payment"`
+		InsertCredit            string `query:"insert-credit"`
+		PaymentRecorded         string `query:"payment-recorded"`
+		GetCreditBalance        string `query:"get-credit-balance"`
+		GetCredits              string `query:"get-credits"`
+		InsertPartialPayment    string `query:"insert-partial-payment"`
+		GetPartialBalance       string `query:"get-partial-balance"`
+		SettlePartialPayments   string `query:"settle-partial-payments"`
+		UnsettlePartialPayments string `query:"unsettle-partial-payments"`
+		ReversePurchaseCredits  string `query:"reverse-purchase-credits"`
 		// InsertPool            string `query:"insert-pool"`
 		// RemovePool            string `query:"remove-pool"`
 		// RemoveToken           string `query:"remove-token"`
@@ -81,13 +149,20 @@ func (pg *Pg) Pool() *pgxpool.Pool {
 	return pg.db
 }
 
-func (pg *Pg) InsertTokenTransfer(ctx context.Context, eventPayload event.Event) error {
+// InsertTokenTransfer indexes a transfer. When purchase is set, its voucher is written to the voucher outbox
+// in the same transaction so that a paid purchase is never lost nor recorded without its transfer.
+func (pg *Pg) InsertTokenTransfer(ctx context.Context, eventPayload event.Event, purchase *Purchase) error {
 	return pg.executeTransaction(ctx, func(tx pgx.Tx) error {
 		txID, err := pg.insertTx(ctx, tx, eventPayload)
 		if err != nil {
 			return err
 		}
 
+		var tenantID string
+		if purchase != nil {
+			tenantID = purchase.TenantID
+		}
+
 		_, err = tx.Exec(
 			ctx,
 			pg.queries.InsertTokenTransfer,
@@ -96,8 +171,120 @@ func (pg *Pg) InsertTokenTransfer(ctx context.Context, eventPayload event.Event)
 			eventPayload.Payload["to"].(string),
 			eventPayload.Payload["value"].(string),
 			eventPayload.ContractAddress,
+			tenantID,
 		)
-		return err
+		if err != nil || purchase == nil {
+			return err
+		}
+
+		// A concurrent delivery of the same event may have recorded the payment since it was priced.
+		var recorded bool
+		if err := tx.QueryRow(ctx, pg.queries.PaymentRecorded, eventPayload.TxHash, eventPayload.Index).Scan(&recorded); err != nil {
+			return err
+		}
+		if recorded {
+			return nil
+		}
+
+		if purchase.Unaccepted {
+			if _, err := tx.Exec(
+				ctx,
+				pg.queries.InsertUnacceptedPayment,
+				txID,
+				eventPayload.Index,
+				eventPayload.Payload["from"].(string),
+				eventPayload.Payload["to"].(string),
+				eventPayload.ContractAddress,
+				eventPayload.Payload["value"].(string),
+			); err != nil {
+				return err
+			}
+		}
+
+		if purchase.Voucher != nil {
+			if err := pg.insertVoucher(ctx, tx, *purchase.Voucher, purchase.Review); err != nil {
+				return err
+			}
+		}
+
+		if purchase.OrderID != "" {
+			if _, err := tx.Exec(
+				ctx,
+				pg.queries.SetOrderPaid,
+				purchase.OrderID,
+				eventPayload.TxHash,
+				eventPayload.Index,
+			); err != nil {
+				return err
+			}
+		}
+
+		if u := purchase.Unmatched; u != nil {
+			if _, err := tx.Exec(
+				ctx,
+				pg.queries.InsertUnmatchedPayment,
+				tenantID,
+				u.TxHash,
+				u.LogIndex,
+				u.SenderAddress,
+				u.RecipientAddress,
+				u.ContractAddress,
+				u.TransferValue,
+				u.TokenAmount,
+				u.TokenSymbol,
+				u.Amount,
+				u.BlockNumber,
+				u.Reason,
+			); err != nil {
+				return err
+			}
+		}
+
+		if purchase.PartialPayment != nil {
+			if _, err := tx.Exec(
+				ctx,
+				pg.queries.InsertPartialPayment,
+				purchase.PartialPayment.SenderAddress,
+				purchase.PartialPayment.Amount,
+				eventPayload.TxHash,
+				eventPayload.Index,
+				purchase.PartialPayment.ExpiresAt.UTC(),
+				tenantID,
+			); err != nil {
+				return err
+			}
+		}
+
+		if purchase.SettlePartials {
+			if _, err := tx.Exec(
+				ctx,
+				pg.queries.SettlePartialPayments,
+				tenantID,
+				ethutils.ChecksumAddress(eventPayload.Payload["from"].(string)),
+				eventTime(eventPayload),
+				eventPayload.TxHash,
+				eventPayload.Index,
+			); err != nil {
+				return err
+			}
+		}
+
+		for _, credit := range purchase.Credits {
+			if _, err := tx.Exec(
+				ctx,
+				pg.queries.InsertCredit,
+				credit.SenderAddress,
+				credit.Amount,
+				credit.Kind,
+				eventPayload.TxHash,
+				eventPayload.Index,
+				tenantID,
+			); err != nil {
+				return err
+			}
+		}
+
+		return nil
 	})
 }
 
@@ -237,16 +424,862 @@ func (pg *Pg) InsertToken(ctx context.Context, contractAddress string, name stri
 	})
 }
 
-func (pg *Pg) GetTokenSymbol(ctx context.Context, contractAddress string) (string, error) {
-	var symbol string
+func (pg *Pg) GetToken(ctx context.Context, contractAddress string) (string, uint8, error) {
+	var (
+		symbol   string
+		decimals uint8
+	)
 	if err := pg.db.QueryRow(
 		ctx,
-		pg.queries.GetTokenSymbol,
+		pg.queries.GetToken,
 		contractAddress,
-	).Scan(&symbol); err != nil {
+	).Scan(&symbol, &decimals); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", 0, ErrNotFound
+		}
+		return "", 0, err
+	}
+	return symbol, decimals, nil
+}
+
+func (pg *Pg) GetCreditBalance(ctx context.Context, tenantID string, senderAddress string) (string, error) {
+	var balance string
+	if err := pg.db.QueryRow(
+		ctx,
+		pg.queries.GetCreditBalance,
+		tenantID,
+		senderAddress,
+	).Scan(&balance); err != nil {
 		return "", err
 	}
-	return symbol, nil
+	return balance, nil
+}
+
+// GetSenderUsage returns the vouchers a sender bought, and what they paid for them, in transfers made between since
+// and until.
+func (pg *Pg) GetSenderUsage(ctx context.Context, tenantID string, senderAddress string, since time.Time, until time.Time) (int, string, error) {
+	var (
+		vouchers int
+		value    string
+	)
+	if err := pg.db.QueryRow(
+		ctx,
+		pg.queries.GetSenderUsage,
+		tenantID,
+		senderAddress,
+		since.UTC(),
+		until.UTC(),
+	).Scan(&vouchers, &value); err != nil {
+		return 0, "", err
+	}
+	return vouchers, value, nil
+}
+
+// GetTenantUsage returns the vouchers all senders of a tenant bought in transfers made between since and until.
+func (pg *Pg) GetTenantUsage(ctx context.Context, tenantID string, since time.Time, until time.Time) (int, error) {
+	var vouchers int
+	if err := pg.db.QueryRow(
+		ctx,
+		pg.queries.GetTenantUsage,
+		tenantID,
+		since.UTC(),
+		until.UTC(),
+	).Scan(&vouchers); err != nil {
+		return 0, err
+	}
+	return vouchers, nil
+}
+
+func (pg *Pg) GetAddress(ctx context.Context, tenantID string, address string) (AddressEntry, error) {
+	rows, err := pg.db.Query(
+		ctx,
+		pg.queries.GetAddress,
+		tenantID,
+		address,
+	)
+	if err != nil {
+		return AddressEntry{}, err
+	}
+
+	entry, err := pgx.CollectExactlyOneRow(rows, scanAddressEntry)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return AddressEntry{}, ErrNotFound
+	}
+	return entry, err
+}
+
+func (pg *Pg) ListAddresses(ctx context.Context, tenantID string) ([]AddressEntry, error) {
+	rows, err := pg.db.Query(
+		ctx,
+		pg.queries.ListAddresses,
+		tenantID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, scanAddressEntry)
+}
+
+func (pg *Pg) UpsertAddress(ctx context.Context, entry *AddressEntry) error {
+	return pg.db.QueryRow(
+		ctx,
+		pg.queries.UpsertAddress,
+		entry.TenantID,
+		entry.Address,
+		entry.Allowed,
+		entry.Blocked,
+		entry.Member,
+		entry.PricingGroup,
+		entry.Note,
+	).Scan(&entry.UpdatedAt)
+}
+
+func (pg *Pg) DeleteAddress(ctx context.Context, tenantID string, address string) error {
+	tag, err := pg.db.Exec(
+		ctx,
+		pg.queries.DeleteAddress,
+		tenantID,
+		address,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (pg *Pg) ListUnmatchedPayments(ctx context.Context, tenantID string, status string) ([]UnmatchedPayment, error) {
+	rows, err := pg.db.Query(
+		ctx,
+		pg.queries.ListUnmatchedPayments,
+		tenantID,
+		status,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, scanUnmatchedPayment)
+}
+
+func (pg *Pg) GetUnmatchedPayment(ctx context.Context, id int) (UnmatchedPayment, error) {
+	rows, err := pg.db.Query(
+		ctx,
+		pg.queries.GetUnmatchedPayment,
+		id,
+	)
+	if err != nil {
+		return UnmatchedPayment{}, err
+	}
+
+	payment, err := pgx.CollectExactlyOneRow(rows, scanUnmatchedPayment)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return UnmatchedPayment{}, ErrNotFound
+	}
+	return payment, err
+}
+
+// ResolveUnmatchedPayment closes an open unmatched payment together with the voucher or credit it resolves to.
+func (pg *Pg) ResolveUnmatchedPayment(ctx context.Context, id int, resolution Resolution) error {
+	return pg.executeTransaction(ctx, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(
+			ctx,
+			pg.queries.ResolveUnmatchedPayment,
+			id,
+			resolution.Status,
+			resolution.Note,
+		)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrResolved
+		}
+
+		if resolution.Voucher != nil {
+			if err := pg.insertVoucher(ctx, tx, *resolution.Voucher, nil); err != nil {
+				return err
+			}
+		}
+
+		if resolution.Refund != nil {
+			if err := pg.insertRefund(ctx, tx, *resolution.Refund); err != nil {
+				return err
+			}
+		}
+
+		if credit := resolution.Credit; credit != nil {
+			if _, err := tx.Exec(
+				ctx,
+				pg.queries.InsertCredit,
+				credit.SenderAddress,
+				credit.Amount,
+				credit.Kind,
+				credit.TxHash,
+				credit.LogIndex,
+				resolution.TenantID,
+			); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// insertRefund queues a refund and, for a purchase, reverses the credit and partial payment entries it left on the
+// ledger, since the refund pays back the whole transfer. ErrDuplicate is returned when the payment already has a
+// refund or an issued voucher, so that a resolution is rolled back instead of claiming a refund that was never
+// queued.
+func (pg *Pg) insertRefund(ctx context.Context, tx pgx.Tx, refund Refund) error {
+	tag, err := tx.Exec(
+		ctx,
+		pg.queries.InsertRefund,
+		refund.TenantID,
+		refund.Source,
+		refund.TxHash,
+		refund.LogIndex,
+		refund.VaultAddress,
+		refund.SenderAddress,
+		refund.ContractAddress,
+		refund.Value,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrDuplicate
+	}
+	if refund.Source != RefundSourceVoucher {
+		return nil
+	}
+
+	return pg.reversePurchase(ctx, tx, refund.TxHash, refund.LogIndex)
+}
+
+// reversePurchase books a reversal of the credits a purchase used or left over and gives back the partial payments
+// it settled, for a purchase that will never be issued. Reversing a purchase twice books nothing the second time.
+func (pg *Pg) reversePurchase(ctx context.Context, tx pgx.Tx, txHash string, logIndex uint) error {
+	if _, err := tx.Exec(ctx, pg.queries.ReversePurchaseCredits, txHash, logIndex); err != nil {
+		return err
+	}
+	_, err := tx.Exec(ctx, pg.queries.UnsettlePartialPayments, txHash, logIndex)
+	return err
+}
+
+func (pg *Pg) FetchRefunds(ctx context.Context, status string, limit int) ([]Refund, error) {
+	rows, err := pg.db.Query(
+		ctx,
+		pg.queries.FetchRefunds,
+		status,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (Refund, error) {
+		var r Refund
+		err := row.Scan(
+			&r.ID,
+			&r.TenantID,
+			&r.Source,
+			&r.TxHash,
+			&r.LogIndex,
+			&r.VaultAddress,
+			&r.SenderAddress,
+			&r.ContractAddress,
+			&r.Value,
+			&r.Status,
+			&r.RefundTxHash,
+			&r.RawTx,
+			&r.Attempts,
+		)
+		return r, err
+	})
+}
+
+func (pg *Pg) SetRefundSent(ctx context.Context, id int, refundTxHash string, rawTx []byte) error {
+	_, err := pg.db.Exec(
+		ctx,
+		pg.queries.SetRefundSent,
+		id,
+		refundTxHash,
+		rawTx,
+	)
+	return err
+}
+
+func (pg *Pg) SetRefundRetry(ctx context.Context, id int, lastError string) error {
+	_, err := pg.db.Exec(
+		ctx,
+		pg.queries.SetRefundRetry,
+		id,
+		lastError,
+	)
+	return err
+}
+
+func (pg *Pg) SetRefundConfirmed(ctx context.Context, id int) error {
+	_, err := pg.db.Exec(
+		ctx,
+		pg.queries.SetRefundConfirmed,
+		id,
+	)
+	return err
+}
+
+func (pg *Pg) SetRefundFailed(ctx context.Context, id int, lastError string) error {
+	_, err := pg.db.Exec(
+		ctx,
+		pg.queries.SetRefundFailed,
+		id,
+		lastError,
+	)
+	return err
+}
+
+// uniqueViolation is the Postgres error code of a unique constraint violation.
+const uniqueViolation = "23505"
+
+// InsertOrder expires stale orders and then reserves the order's transfer value. ErrDuplicate is returned when
+// an open order already holds the same value.
+func (pg *Pg) InsertOrder(ctx context.Context, order *Order) error {
+	return pg.executeTransaction(ctx, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, pg.queries.ExpireOrders); err != nil {
+			return err
+		}
+
+		err := tx.QueryRow(
+			ctx,
+			pg.queries.InsertOrder,
+			order.ID,
+			order.TenantID,
+			order.VaultAddress,
+			order.Tier,
+			order.TierDescription,
+			order.ContractAddress,
+			order.TokenSymbol,
+			order.TokenAmount,
+			order.Value,
+			order.ExpiresAt.UTC(),
+		).Scan(&order.CreatedAt)
+
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return ErrDuplicate
+		}
+		return err
+	})
+}
+
+func (pg *Pg) GetOrder(ctx context.Context, id string) (Order, error) {
+	var o Order
+	err := pg.db.QueryRow(
+		ctx,
+		pg.queries.GetOrder,
+		id,
+	).Scan(
+		&o.ID,
+		&o.TenantID,
+		&o.VaultAddress,
+		&o.Tier,
+		&o.TierDescription,
+		&o.ContractAddress,
+		&o.TokenSymbol,
+		&o.TokenAmount,
+		&o.Value,
+		&o.Status,
+		&o.TxHash,
+		&o.VoucherStatus,
+		&o.ExpiresAt,
+		&o.CreatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Order{}, ErrNotFound
+	}
+	return o, err
+}
+
+// CountOpenOrders returns the number of unexpired open orders of a vault.
+func (pg *Pg) CountOpenOrders(ctx context.Context, vaultAddress string) (int, error) {
+	var count int
+	err := pg.db.QueryRow(ctx, pg.queries.CountOpenOrders, vaultAddress).Scan(&count)
+	return count, err
+}
+
+// GetOpenOrder returns the id and tier of the open order reserving value, if it had not expired at the time
+// of the payment.
+func (pg *Pg) GetOpenOrder(ctx context.Context, vaultAddress string, contractAddress string, value string, at time.Time) (string, string, error) {
+	var id, tier string
+	err := pg.db.QueryRow(
+		ctx,
+		pg.queries.GetOpenOrder,
+		vaultAddress,
+		contractAddress,
+		value,
+		at.UTC(),
+	).Scan(&id, &tier)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", "", ErrNotFound
+	}
+	return id, tier, err
+}
+
+// ImportPoolCodes adds codes to the pool of a vault and returns how many were new. Codes already in the pool
+// are skipped, so a file can be imported again after a partial failure.
+func (pg *Pg) ImportPoolCodes(ctx context.Context, vaultAddress string, codes []PoolCode) (int, error) {
+	var imported int
+	err := pg.executeTransaction(ctx, func(tx pgx.Tx) error {
+		for _, c := range codes {
+			tag, err := tx.Exec(ctx, pg.queries.InsertPoolCode, vaultAddress, c.ProfilePK, c.Code)
+			if err != nil {
+				return err
+			}
+			imported += int(tag.RowsAffected())
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return imported, nil
+}
+
+// ClaimPoolCode hands out the next unused code for a profile to a voucher item, together with the number of
+// codes left. An item that already claimed a code gets the same code back. ErrNotFound means the pool is empty.
+func (pg *Pg) ClaimPoolCode(ctx context.Context, vaultAddress string, profilePK int, itemID int) (string, int, error) {
+	var (
+		code      string
+		remaining int
+	)
+	err := pg.executeTransaction(ctx, func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx, pg.queries.GetPoolClaim, itemID).Scan(&code)
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = tx.QueryRow(ctx, pg.queries.ClaimPoolCode, vaultAddress, profilePK, itemID).Scan(&code)
+		}
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		return tx.QueryRow(ctx, pg.queries.CountPoolCodes, vaultAddress, profilePK).Scan(&remaining)
+	})
+	if err != nil {
+		return "", 0, err
+	}
+	return code, remaining, nil
+}
+
+func (pg *Pg) ListPoolStock(ctx context.Context, vaultAddress string) ([]PoolStock, error) {
+	rows, err := pg.db.Query(ctx, pg.queries.ListPoolStock, vaultAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (PoolStock, error) {
+		s := PoolStock{VaultAddress: vaultAddress}
+		err := row.Scan(&s.ProfilePK, &s.Available, &s.Claimed)
+		return s, err
+	})
+}
+
+func (pg *Pg) ListReviews(ctx context.Context, tenantID string, status string) ([]ReviewEntry, error) {
+	rows, err := pg.db.Query(ctx, pg.queries.ListReviews, tenantID, status)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, scanReview)
+}
+
+func (pg *Pg) GetReview(ctx context.Context, id int) (ReviewEntry, error) {
+	rows, err := pg.db.Query(ctx, pg.queries.GetReview, id)
+	if err != nil {
+		return ReviewEntry{}, err
+	}
+
+	entry, err := pgx.CollectExactlyOneRow(rows, scanReview)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ReviewEntry{}, ErrNotFound
+	}
+	return entry, err
+}
+
+// ResolveReview closes an open review and moves its held voucher to pending or rejected in the same transaction.
+// A rejection reverses the credit and partial payment entries of the purchase and queues its refund when set. It
+// returns ErrResolved when the review was already resolved.
+func (pg *Pg) ResolveReview(ctx context.Context, id int, resolution ReviewResolution) error {
+	return pg.executeTransaction(ctx, func(tx pgx.Tx) error {
+		var voucherID int
+		err := tx.QueryRow(
+			ctx,
+			pg.queries.ResolveReview,
+			id,
+			resolution.Status,
+			resolution.Note,
+		).Scan(&voucherID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrResolved
+		}
+		if err != nil {
+			return err
+		}
+
+		if resolution.Status != ReviewStatusRejected {
+			tag, err := tx.Exec(ctx, pg.queries.ReleaseHeldVoucher, voucherID)
+			if err != nil {
+				return err
+			}
+			if tag.RowsAffected() == 0 {
+				return ErrResolved
+			}
+			return nil
+		}
+
+		var (
+			txHash   string
+			logIndex uint
+		)
+		err = tx.QueryRow(
+			ctx,
+			pg.queries.RejectHeldVoucher,
+			voucherID,
+			"rejected in review: "+resolution.Note,
+		).Scan(&txHash, &logIndex)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrResolved
+		}
+		if err != nil {
+			return err
+		}
+
+		// A rejected purchase is never issued, its ledger entries are reversed whether or not it is refunded.
+		if resolution.Refund != nil {
+			return pg.insertRefund(ctx, tx, *resolution.Refund)
+		}
+		return pg.reversePurchase(ctx, tx, txHash, logIndex)
+	})
+}
+
+func scanReview(row pgx.CollectableRow) (ReviewEntry, error) {
+	var r ReviewEntry
+	err := row.Scan(
+		&r.ID,
+		&r.VoucherID,
+		&r.TenantID,
+		&r.SenderAddress,
+		&r.Rule,
+		&r.Reason,
+		&r.Status,
+		&r.Note,
+		&r.TxHash,
+		&r.LogIndex,
+		&r.RecipientAddress,
+		&r.ContractAddress,
+		&r.TransferValue,
+		&r.TokenAmount,
+		&r.TokenSymbol,
+		&r.Price,
+		&r.CreatedAt,
+		&r.ResolvedAt,
+	)
+	return r, err
+}
+
+func scanUnmatchedPayment(row pgx.CollectableRow) (UnmatchedPayment, error) {
+	var u UnmatchedPayment
+	err := row.Scan(
+		&u.ID,
+		&u.TenantID,
+		&u.TxHash,
+		&u.LogIndex,
+		&u.SenderAddress,
+		&u.RecipientAddress,
+		&u.ContractAddress,
+		&u.TransferValue,
+		&u.TokenAmount,
+		&u.TokenSymbol,
+		&u.Amount,
+		&u.BlockNumber,
+		&u.Reason,
+		&u.Status,
+		&u.ResolutionNote,
+		&u.CreatedAt,
+		&u.ResolvedAt,
+	)
+	return u, err
+}
+
+func scanAddressEntry(row pgx.CollectableRow) (AddressEntry, error) {
+	var e AddressEntry
+	err := row.Scan(
+		&e.TenantID,
+		&e.Address,
+		&e.Allowed,
+		&e.Blocked,
+		&e.Member,
+		&e.PricingGroup,
+		&e.Note,
+		&e.UpdatedAt,
+	)
+	return e, err
+}
+
+func (pg *Pg) GetCredits(ctx context.Context, tenantID string, senderAddress string) ([]Credit, error) {
+	rows, err := pg.db.Query(
+		ctx,
+		pg.queries.GetCredits,
+		tenantID,
+		senderAddress,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (Credit, error) {
+		c := Credit{SenderAddress: senderAddress}
+		err := row.Scan(
+			&c.Amount,
+			&c.Kind,
+			&c.TxHash,
+			&c.LogIndex,
+			&c.CreatedAt,
+		)
+		return c, err
+	})
+}
+
+func (pg *Pg) SetVoucherRejected(ctx context.Context, id int, reason string) error {
+	_, err := pg.db.Exec(
+		ctx,
+		pg.queries.SetVoucherRejected,
+		id,
+		reason,
+	)
+	return err
+}
+
+func (pg *Pg) ExtendSubscription(ctx context.Context, subscription Subscription, days int) (time.Time, error) {
+	var endsAt time.Time
+	if err := pg.db.QueryRow(
+		ctx,
+		pg.queries.ExtendSubscription,
+		subscription.TenantID,
+		subscription.VaultAddress,
+		subscription.SenderAddress,
+		subscription.Tier,
+		subscription.TierDescription,
+		days,
+	).Scan(&endsAt); err != nil {
+		return time.Time{}, err
+	}
+	return endsAt, nil
+}
+
+func (pg *Pg) GetExpiringSubscriptions(ctx context.Context, before time.Time) ([]Subscription, error) {
+	rows, err := pg.db.Query(
+		ctx,
+		pg.queries.GetExpiringSubscriptions,
+		before.UTC(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (Subscription, error) {
+		var s Subscription
+		err := row.Scan(
+			&s.ID,
+			&s.TenantID,
+			&s.VaultAddress,
+			&s.SenderAddress,
+			&s.Tier,
+			&s.TierDescription,
+			&s.StartsAt,
+			&s.EndsAt,
+		)
+		return s, err
+	})
+}
+
+func (pg *Pg) SetSubscriptionReminded(ctx context.Context, id int) error {
+	_, err := pg.db.Exec(
+		ctx,
+		pg.queries.SetSubscriptionReminded,
+		id,
+	)
+	return err
+}
+
+func (pg *Pg) GetPartialBalance(ctx context.Context, tenantID string, senderAddress string, at time.Time) (string, error) {
+	var balance string
+	if err := pg.db.QueryRow(
+		ctx,
+		pg.queries.GetPartialBalance,
+		tenantID,
+		senderAddress,
+		at.UTC(),
+	).Scan(&balance); err != nil {
+		return "", err
+	}
+	return balance, nil
+}
+
+// PaymentRecorded reports whether a payment already bought a voucher or left an unmatched, credit or partial
+// payment entry.
+func (pg *Pg) PaymentRecorded(ctx context.Context, txHash string, logIndex uint) (bool, error) {
+	var recorded bool
+	err := pg.db.QueryRow(ctx, pg.queries.PaymentRecorded, txHash, logIndex).Scan(&recorded)
+	return recorded, err
+}
+
+// FetchDueVouchers claims pending vouchers that are due, and vouchers stuck in processing for longer than lease.
+func (pg *Pg) FetchDueVouchers(ctx context.Context, limit int, maxBlockNumber uint64, lease time.Duration) ([]Voucher, error) {
+	rows, err := pg.db.Query(
+		ctx,
+		pg.queries.FetchDueVouchers,
+		limit,
+		min(maxBlockNumber, math.MaxInt64),
+		lease.Seconds(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	vouchers, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (Voucher, error) {
+		var v Voucher
+		err := row.Scan(
+			&v.ID,
+			&v.TenantID,
+			&v.TxHash,
+			&v.LogIndex,
+			&v.SenderAddress,
+			&v.RecipientAddress,
+			&v.ContractAddress,
+			&v.TransferValue,
+			&v.Amount,
+			&v.TokenSymbol,
+			&v.BlockNumber,
+			&v.Attempts,
+		)
+		return v, err
+	})
+	if err != nil || len(vouchers) == 0 {
+		return vouchers, err
+	}
+
+	if err := pg.loadVoucherItems(ctx, vouchers); err != nil {
+		return nil, err
+	}
+
+	return vouchers, nil
+}
+
+func (pg *Pg) loadVoucherItems(ctx context.Context, vouchers []Voucher) error {
+	ids := make([]int, len(vouchers))
+	byID := make(map[int]*Voucher, len(vouchers))
+	for i := range vouchers {
+		ids[i] = vouchers[i].ID
+		byID[vouchers[i].ID] = &vouchers[i]
+	}
+
+	rows, err := pg.db.Query(
+		ctx,
+		pg.queries.GetVoucherItems,
+		ids,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			voucherID int
+			item      VoucherItem
+		)
+		if err := rows.Scan(
+			&item.ID,
+			&voucherID,
+			&item.Tier,
+			&item.ProfilePK,
+			&item.Description,
+			&item.SubscriptionDays,
+			&item.Code,
+		); err != nil {
+			return err
+		}
+
+		if v, ok := byID[voucherID]; ok {
+			v.Items = append(v.Items, item)
+		}
+	}
+
+	return rows.Err()
+}
+
+func (pg *Pg) SetVoucherItemIssued(ctx context.Context, id int, voucherCode string) error {
+	_, err := pg.db.Exec(
+		ctx,
+		pg.queries.SetVoucherItemIssued,
+		id,
+		voucherCode,
+	)
+	return err
+}
+
+func (pg *Pg) SetVoucherIssued(ctx context.Context, id int) error {
+	_, err := pg.db.Exec(
+		ctx,
+		pg.queries.SetVoucherIssued,
+		id,
+	)
+	return err
+}
+
+func (pg *Pg) SetVoucherRetry(ctx context.Context, id int, nextAttemptAt time.Time, lastError string) error {
+	_, err := pg.db.Exec(
+		ctx,
+		pg.queries.SetVoucherRetry,
+		id,
+		nextAttemptAt.UTC(),
+		lastError,
+	)
+	return err
+}
+
+// SetVoucherFailed gives up on a voucher and queues refund with it in one transaction, so that a failed voucher
+// is never left without its refund. It reports whether the refund was queued, a payment that was partly issued or
+// already refunded is not refunded again.
+func (pg *Pg) SetVoucherFailed(ctx context.Context, id int, lastError string, refund *Refund) (bool, error) {
+	var queued bool
+	err := pg.executeTransaction(ctx, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(
+			ctx,
+			pg.queries.SetVoucherFailed,
+			id,
+			lastError,
+		); err != nil {
+			return err
+		}
+		if refund == nil {
+			return nil
+		}
+
+		err := pg.insertRefund(ctx, tx, *refund)
+		if errors.Is(err, ErrDuplicate) {
+			return nil
+		}
+		queued = err == nil
+		return err
+	})
+	return queued, err
 }
 
 // func (pg *Pg) InsertPool(ctx context.Context, contractAddress string, name string, symbol string) error {
@@ -293,7 +1326,7 @@ func (pg *Pg) insertTx(ctx context.Context, tx pgx.Tx, eventPayload event.Event)
 		pg.queries.InsertTx,
 		eventPayload.TxHash,
 		eventPayload.Block,
-		time.Unix(int64(eventPayload.Timestamp), 0).UTC(),
+		eventTime(eventPayload),
 		eventPayload.Success,
 	).Scan(&txID); err != nil {
 		return 0, err
@@ -301,6 +1334,73 @@ func (pg *Pg) insertTx(ctx context.Context, tx pgx.Tx, eventPayload event.Event)
 	return txID, nil
 }
 
+// insertVoucher writes a voucher and its items to the outbox. A voucher that was already queued by an earlier
+// delivery of the same transfer is left untouched. A voucher with a review is held and added to the review queue.
+func (pg *Pg) insertVoucher(ctx context.Context, tx pgx.Tx, voucher Voucher, review *Review) error {
+	status := VoucherStatusPending
+	if review != nil {
+		status = VoucherStatusHeld
+	}
+
+	var voucherID int
+	if err := tx.QueryRow(
+		ctx,
+		pg.queries.InsertVoucher,
+		voucher.TxHash,
+		voucher.LogIndex,
+		voucher.SenderAddress,
+		voucher.RecipientAddress,
+		voucher.ContractAddress,
+		voucher.TransferValue,
+		voucher.Amount,
+		voucher.TokenSymbol,
+		voucher.BlockNumber,
+		voucher.TenantID,
+		voucher.Price,
+		status,
+	).Scan(&voucherID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+
+	for i, item := range voucher.Items {
+		if _, err := tx.Exec(
+			ctx,
+			pg.queries.InsertVoucherItem,
+			voucherID,
+			i,
+			item.Tier,
+			item.ProfilePK,
+			item.Description,
+			item.SubscriptionDays,
+		); err != nil {
+			return err
+		}
+	}
+
+	if review != nil {
+		if _, err := tx.Exec(
+			ctx,
+			pg.queries.InsertReview,
+			voucherID,
+			voucher.TenantID,
+			voucher.SenderAddress,
+			review.Rule,
+			review.Reason,
+		); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func eventTime(eventPayload event.Event) time.Time {
+	return time.Unix(int64(eventPayload.Timestamp), 0).UTC()
+}
+
 func (pg *Pg) executeTransaction(ctx context.Context, fn func(tx pgx.Tx) error) error {
 	tx, err := pg.db.Begin(ctx)
 	if err != nil {
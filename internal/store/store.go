@@ -2,14 +2,54 @@ package store
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/grassrootseconomics/eth-tracker/pkg/event"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+const (
+	CreditKindOverpayment = "overpayment"
+	CreditKindPurchase    = "purchase"
+	// CreditKindUnmatched credits an unmatched payment resolved by an admin.
+	CreditKindUnmatched = "unmatched"
+	// CreditKindReversal cancels the credit entries of a purchase that was refunded.
+	CreditKindReversal = "reversal"
+
+	VoucherStatusPending = "pending"
+	// VoucherStatusHeld keeps a purchase that broke a limit rule out of the outbox until it is reviewed.
+	VoucherStatusHeld = "held"
+
+	ReviewStatusOpen     = "open"
+	ReviewStatusApproved = "approved"
+	ReviewStatusRejected = "rejected"
+
+	UnmatchedStatusOpen     = "open"
+	UnmatchedStatusIssued   = "issued"
+	UnmatchedStatusCredited = "credited"
+	UnmatchedStatusRefunded = "refunded"
+
+	RefundSourceVoucher   = "voucher"
+	RefundSourceUnmatched = "unmatched"
+
+	RefundStatusPending = "pending"
+	RefundStatusSent    = "sent"
+
+	OrderStatusOpen = "open"
+)
+
+var (
+	ErrNotFound = errors.New("store: not found")
+	// ErrResolved is returned when resolving an unmatched payment that is no longer open.
+	ErrResolved = errors.New("store: already resolved")
+	// ErrDuplicate is returned when an insert conflicts with an existing row.
+	ErrDuplicate = errors.New("store: duplicate")
+)
+
 type (
 	Store interface {
-		InsertTokenTransfer(context.Context, event.Event) error
+		InsertTokenTransfer(context.Context, event.Event, *Purchase) error
 		// InsertTokenMint(context.Context, event.Event) error
 		// InsertTokenBurn(context.Context, event.Event) error
 		// InsertFaucetGive(context.Context, event.Event) error
@@ -17,10 +57,257 @@ type (
 		// InsertPoolDeposit(context.Context, event.Event) error
 		// InsertOwnershipChange(context.Context, event.Event) error
 		InsertToken(context.Context, string, string, string, uint8, string) error
-		GetTokenSymbol(context.Context, string) (string, error)
+		GetToken(context.Context, string) (string, uint8, error)
+		PaymentRecorded(context.Context, string, uint) (bool, error)
+		GetCreditBalance(context.Context, string, string) (string, error)
+		GetCredits(context.Context, string, string) ([]Credit, error)
+		GetPartialBalance(context.Context, string, string, time.Time) (string, error)
+		FetchDueVouchers(context.Context, int, uint64, time.Duration) ([]Voucher, error)
+		SetVoucherItemIssued(context.Context, int, string) error
+		SetVoucherIssued(context.Context, int) error
+		SetVoucherRetry(context.Context, int, time.Time, string) error
+		SetVoucherFailed(context.Context, int, string, *Refund) (bool, error)
+		SetVoucherRejected(context.Context, int, string) error
+		ExtendSubscription(context.Context, Subscription, int) (time.Time, error)
+		GetExpiringSubscriptions(context.Context, time.Time) ([]Subscription, error)
+		SetSubscriptionReminded(context.Context, int) error
+		GetSenderUsage(context.Context, string, string, time.Time, time.Time) (int, string, error)
+		GetTenantUsage(context.Context, string, time.Time, time.Time) (int, error)
+		ListReviews(context.Context, string, string) ([]ReviewEntry, error)
+		GetReview(context.Context, int) (ReviewEntry, error)
+		ResolveReview(context.Context, int, ReviewResolution) error
+		GetAddress(context.Context, string, string) (AddressEntry, error)
+		ListAddresses(context.Context, string) ([]AddressEntry, error)
+		UpsertAddress(context.Context, *AddressEntry) error
+		DeleteAddress(context.Context, string, string) error
+		ListUnmatchedPayments(context.Context, string, string) ([]UnmatchedPayment, error)
+		GetUnmatchedPayment(context.Context, int) (UnmatchedPayment, error)
+		ResolveUnmatchedPayment(context.Context, int, Resolution) error
+		FetchRefunds(context.Context, string, int) ([]Refund, error)
+		SetRefundSent(context.Context, int, string, []byte) error
+		SetRefundRetry(context.Context, int, string) error
+		SetRefundConfirmed(context.Context, int) error
+		SetRefundFailed(context.Context, int, string) error
+		InsertOrder(context.Context, *Order) error
+		GetOrder(context.Context, string) (Order, error)
+		GetOpenOrder(context.Context, string, string, string, time.Time) (string, string, error)
+		CountOpenOrders(context.Context, string) (int, error)
+		ImportPoolCodes(context.Context, string, []PoolCode) (int, error)
+		ClaimPoolCode(context.Context, string, int, int) (string, int, error)
+		ListPoolStock(context.Context, string) ([]PoolStock, error)
 		// InsertPool(context.Context, string, string, string) error
 		// RemoveContractAddress(context.Context, event.Event) error
 		Pool() *pgxpool.Pool
 		Close()
 	}
+
+	// Purchase holds the outcome of a transfer to a vault, persisted together with the transfer itself. Credits
+	// and partial payments are kept per tenant since tenants price in different units.
+	Purchase struct {
+		TenantID       string
+		Voucher        *Voucher
+		Unaccepted     bool
+		Credits        []Credit
+		PartialPayment *PartialPayment
+		// SettlePartials marks the sender's open partial payments as spent on this purchase.
+		SettlePartials bool
+		// Review holds the voucher for manual review instead of queueing it for issuance.
+		Review *Review
+		// Unmatched records an accepted payment that bought nothing.
+		Unmatched *UnmatchedPayment
+		// OrderID marks the order paid by this purchase.
+		OrderID string
+	}
+
+	// Order reserves a unique transfer value for a tier, so that a payment of exactly Value buys it. Once paid,
+	// VoucherStatus follows the voucher through the outbox.
+	Order struct {
+		ID              string    `json:"id"`
+		TenantID        string    `json:"tenantId"`
+		VaultAddress    string    `json:"vaultAddress"`
+		Tier            string    `json:"tier"`
+		TierDescription string    `json:"tierDescription"`
+		ContractAddress string    `json:"contractAddress"`
+		TokenSymbol     string    `json:"tokenSymbol"`
+		TokenAmount     string    `json:"tokenAmount"`
+		Value           string    `json:"value"`
+		Status          string    `json:"status"`
+		TxHash          string    `json:"txHash,omitempty"`
+		VoucherStatus   string    `json:"voucherStatus,omitempty"`
+		ExpiresAt       time.Time `json:"expiresAt"`
+		CreatedAt       time.Time `json:"createdAt"`
+	}
+
+	// PoolCode is a pre-generated voucher code for a RadiusDesk profile.
+	PoolCode struct {
+		ProfilePK int
+		Code      string
+	}
+
+	// PoolStock counts the pooled codes of a vault for one profile.
+	PoolStock struct {
+		VaultAddress string `json:"vaultAddress"`
+		ProfilePK    int    `json:"profilePk"`
+		Available    int    `json:"available"`
+		Claimed      int    `json:"claimed"`
+	}
+
+	// UnmatchedPayment is an accepted payment to a vault that matched no tier or was refused. Amount is in
+	// tier price units, TokenAmount in token units.
+	UnmatchedPayment struct {
+		ID               int        `json:"id"`
+		TenantID         string     `json:"tenantId"`
+		TxHash           string     `json:"txHash"`
+		LogIndex         uint       `json:"logIndex"`
+		SenderAddress    string     `json:"senderAddress"`
+		RecipientAddress string     `json:"recipientAddress"`
+		ContractAddress  string     `json:"contractAddress"`
+		TransferValue    string     `json:"transferValue"`
+		TokenAmount      string     `json:"tokenAmount"`
+		TokenSymbol      string     `json:"tokenSymbol"`
+		Amount           string     `json:"amount"`
+		BlockNumber      uint64     `json:"blockNumber"`
+		Reason           string     `json:"reason"`
+		Status           string     `json:"status"`
+		ResolutionNote   string     `json:"resolutionNote"`
+		CreatedAt        time.Time  `json:"createdAt"`
+		ResolvedAt       *time.Time `json:"resolvedAt"`
+	}
+
+	// Resolution closes an unmatched payment, queueing Voucher or Refund or recording Credit along with the new
+	// status.
+	Resolution struct {
+		TenantID string
+		Status   string
+		Note     string
+		Voucher  *Voucher
+		Credit   *Credit
+		Refund   *Refund
+	}
+
+	// Refund is an ERC20 transfer of Value from the vault back to the sender of a payment. RawTx holds the signed
+	// transaction once it has been sent, so that it can be broadcast again.
+	Refund struct {
+		ID              int
+		TenantID        string
+		Source          string
+		TxHash          string
+		LogIndex        uint
+		VaultAddress    string
+		SenderAddress   string
+		ContractAddress string
+		Value           string
+		Status          string
+		RefundTxHash    string
+		RawTx           []byte
+		Attempts        int
+	}
+
+	// Review is the reason a purchase was held.
+	Review struct {
+		Rule   string
+		Reason string
+	}
+
+	// ReviewEntry is a held purchase in the review queue together with the payment it was held for.
+	ReviewEntry struct {
+		ID               int        `json:"id"`
+		VoucherID        int        `json:"voucherId"`
+		TenantID         string     `json:"tenantId"`
+		SenderAddress    string     `json:"senderAddress"`
+		Rule             string     `json:"rule"`
+		Reason           string     `json:"reason"`
+		Status           string     `json:"status"`
+		Note             string     `json:"note"`
+		TxHash           string     `json:"txHash"`
+		LogIndex         uint       `json:"logIndex"`
+		RecipientAddress string     `json:"recipientAddress"`
+		ContractAddress  string     `json:"contractAddress"`
+		TransferValue    string     `json:"transferValue"`
+		TokenAmount      string     `json:"tokenAmount"`
+		TokenSymbol      string     `json:"tokenSymbol"`
+		Price            string     `json:"price"`
+		CreatedAt        time.Time  `json:"createdAt"`
+		ResolvedAt       *time.Time `json:"resolvedAt"`
+	}
+
+	// ReviewResolution approves a held purchase, releasing its voucher to the outbox, or rejects it. A rejected
+	// purchase is refunded on chain when Refund is set.
+	ReviewResolution struct {
+		Status string
+		Note   string
+		Refund *Refund
+	}
+
+	// PartialPayment is a payment below the cheapest tier, held until the sender's running total reaches a tier.
+	PartialPayment struct {
+		SenderAddress string
+		Amount        string
+		ExpiresAt     time.Time
+	}
+
+	// Credit is a signed entry in a sender's credit ledger, in tier price units.
+	Credit struct {
+		SenderAddress string    `json:"-"`
+		Amount        string    `json:"amount"`
+		Kind          string    `json:"kind"`
+		TxHash        string    `json:"txHash"`
+		LogIndex      uint      `json:"logIndex"`
+		CreatedAt     time.Time `json:"createdAt"`
+	}
+
+	// Voucher is a paid purchase waiting in, or drained from, the voucher outbox. A single payment can buy
+	// several vouchers, one per item.
+	Voucher struct {
+		ID               int
+		TenantID         string
+		TxHash           string
+		LogIndex         uint
+		SenderAddress    string
+		RecipientAddress string
+		ContractAddress  string
+		TransferValue    string
+		Amount           string
+		TokenSymbol      string
+		// Price is the tier price of all items, in tier price units.
+		Price       string
+		BlockNumber uint64
+		Attempts    int
+		Items       []VoucherItem
+	}
+
+	// VoucherItem is a single voucher code bought by a payment. Code is empty until it has been issued.
+	// Items with SubscriptionDays start or extend a subscription once issued.
+	VoucherItem struct {
+		ID               int
+		Tier             string
+		ProfilePK        int
+		Description      string
+		SubscriptionDays int
+		Code             string
+	}
+
+	// AddressEntry is a sender in a tenant's address registry.
+	AddressEntry struct {
+		TenantID     string    `json:"tenantId"`
+		Address      string    `json:"address"`
+		Allowed      bool      `json:"allowed"`
+		Blocked      bool      `json:"blocked"`
+		Member       bool      `json:"member"`
+		PricingGroup string    `json:"pricingGroup"`
+		Note         string    `json:"note"`
+		UpdatedAt    time.Time `json:"updatedAt"`
+	}
+
+	// Subscription is the period a sender's recurring tier is valid for.
+	Subscription struct {
+		ID              int
+		TenantID        string
+		VaultAddress    string
+		SenderAddress   string
+		Tier            string
+		TierDescription string
+		StartsAt        time.Time
+		EndsAt          time.Time
+	}
 )
@@ -0,0 +1,41 @@
+package vault
+
+import "fmt"
+
+const (
+	// AccessOpen lets any sender buy vouchers.
+	AccessOpen = "open"
+	// AccessRestricted only lets senders flagged as allowed or member in the address registry buy vouchers.
+	AccessRestricted = "restricted"
+
+	// UnlistedRefuse ignores purchases by other senders of a restricted vault.
+	UnlistedRefuse = "refuse"
+	// UnlistedHold holds purchases by other senders of a restricted vault for manual review.
+	UnlistedHold = "hold"
+
+	// RuleUnlisted is the review rule of a purchase held by UnlistedHold.
+	RuleUnlisted = "unlisted_sender"
+)
+
+// Access decides who may buy vouchers from a vault. Blocked senders are always refused.
+type Access struct {
+	Mode     string
+	Unlisted string
+}
+
+func (a Access) Validate() error {
+	switch a.Mode {
+	case AccessOpen:
+		return nil
+	case AccessRestricted:
+	default:
+		return fmt.Errorf("vault: unknown access mode %q", a.Mode)
+	}
+
+	switch a.Unlisted {
+	case UnlistedRefuse, UnlistedHold:
+		return nil
+	default:
+		return fmt.Errorf("vault: unknown unlisted sender action %q", a.Unlisted)
+	}
+}
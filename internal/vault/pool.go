@@ -0,0 +1,32 @@
+package vault
+
+import "fmt"
+
+const (
+	// PoolDisabled always issues vouchers with the vault's provider.
+	PoolDisabled = "disabled"
+	// PoolFallback hands out pooled codes when the provider fails to issue a voucher.
+	PoolFallback = "fallback"
+	// PoolOnly issues every voucher from the pool, for sites without a reachable provider.
+	PoolOnly = "only"
+)
+
+// Pool decides when a vault issues pre-generated voucher codes imported by an admin.
+type Pool struct {
+	Mode string
+	// LowStock is the number of codes left for a tier at or below which an alert is raised.
+	LowStock int
+}
+
+func (p Pool) Validate() error {
+	switch p.Mode {
+	case PoolDisabled, PoolFallback, PoolOnly:
+	default:
+		return fmt.Errorf("vault: unknown pool mode %q", p.Mode)
+	}
+
+	if p.LowStock < 0 {
+		return fmt.Errorf("vault: pool low stock threshold can not be negative")
+	}
+	return nil
+}
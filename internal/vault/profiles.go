@@ -0,0 +1,82 @@
+package vault
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/pricing"
+)
+
+type (
+	// ProfileMapping is the RadiusDesk profile a tier resolved to in the last profile sync. Missing tiers point
+	// at a profile the site does not have.
+	ProfileMapping struct {
+		Tier      string `json:"tier"`
+		Profile   string `json:"profile,omitempty"`
+		ProfilePK int    `json:"profilePk"`
+		Missing   bool   `json:"missing"`
+	}
+
+	// ProfileMap holds the synced profile of every tier of a vault. Until the first sync, and for tiers the
+	// sync could not resolve, the configured profile pk is used.
+	ProfileMap struct {
+		mu       sync.RWMutex
+		mappings map[string]ProfileMapping
+		syncedAt time.Time
+	}
+)
+
+func NewProfileMap() *ProfileMap {
+	return &ProfileMap{
+		mappings: make(map[string]ProfileMapping),
+	}
+}
+
+// PK returns the profile pk to issue vouchers of a tier with.
+func (m *ProfileMap) PK(t pricing.Tier) int {
+	return m.Resolve(t.ID, t.ProfilePK)
+}
+
+// Resolve returns the synced profile pk of a tier, or fallback when there is none.
+func (m *ProfileMap) Resolve(tierID string, fallback int) int {
+	if m == nil {
+		return fallback
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	mapping, ok := m.mappings[tierID]
+	if !ok || mapping.Missing {
+		return fallback
+	}
+	return mapping.ProfilePK
+}
+
+// Set replaces the mappings with the result of a sync.
+func (m *ProfileMap) Set(mappings []ProfileMapping, at time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.mappings = make(map[string]ProfileMapping, len(mappings))
+	for _, mapping := range mappings {
+		m.mappings[mapping.Tier] = mapping
+	}
+	m.syncedAt = at
+}
+
+// Mappings returns the mappings of the last sync and when it ran, the zero time if it never did.
+func (m *ProfileMap) Mappings() ([]ProfileMapping, time.Time) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	mappings := make([]ProfileMapping, 0, len(m.mappings))
+	for _, mapping := range m.mappings {
+		mappings = append(mappings, mapping)
+	}
+	sort.Slice(mappings, func(i, j int) bool {
+		return mappings[i].Tier < mappings[j].Tier
+	})
+	return mappings, m.syncedAt
+}
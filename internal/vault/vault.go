@@ -0,0 +1,94 @@
+package vault
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/limits"
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/pricing"
+	"github.com/grassrootseconomics/eth-indexer/v2/internal/provider"
+	"github.com/grassrootseconomics/eth-indexer/v2/pkg/notify"
+	"github.com/grassrootseconomics/ethutils"
+)
+
+type (
+	// Vault is an address that sells vouchers for a single site of a tenant, issued by the site's provider.
+	Vault struct {
+		TenantID      string
+		Address       string
+		Tiers         *pricing.Tiers
+		Decomposition pricing.Decomposition
+		Tokens        *pricing.Tokens
+		PricingGroups *pricing.Groups
+		Access        Access
+		Limits        limits.Rules
+		Provider      provider.VoucherProvider
+		NotifyClient  *notify.NotifyClient
+		Pool          Pool
+		// Profiles resolves the RadiusDesk profile of each tier, kept current by the profile sync.
+		Profiles *ProfileMap
+	}
+
+	Registry struct {
+		vaults map[string]Vault
+	}
+)
+
+func NewRegistry(vaults []Vault) (*Registry, error) {
+	if len(vaults) == 0 {
+		return nil, fmt.Errorf("vault: no vaults configured")
+	}
+
+	registry := make(map[string]Vault, len(vaults))
+	for _, v := range vaults {
+		if !common.IsHexAddress(v.Address) {
+			return nil, fmt.Errorf("vault: invalid vault address %q", v.Address)
+		}
+		if v.TenantID == "" {
+			return nil, fmt.Errorf("vault: %s has no tenant", v.Address)
+		}
+		if v.Tiers == nil || v.Tokens == nil || v.PricingGroups == nil {
+			return nil, fmt.Errorf("vault: %s has no pricing", v.Address)
+		}
+		if err := v.Access.Validate(); err != nil {
+			return nil, fmt.Errorf("vault: %s: %w", v.Address, err)
+		}
+		if err := v.Limits.Validate(); err != nil {
+			return nil, fmt.Errorf("vault: %s: %w", v.Address, err)
+		}
+		if err := v.Pool.Validate(); err != nil {
+			return nil, fmt.Errorf("vault: %s: %w", v.Address, err)
+		}
+		if v.Provider == nil || v.NotifyClient == nil {
+			return nil, fmt.Errorf("vault: %s has no voucher provider or notify client", v.Address)
+		}
+
+		if v.Profiles == nil {
+			v.Profiles = NewProfileMap()
+		}
+
+		v.Address = ethutils.ChecksumAddress(v.Address)
+		if _, ok := registry[v.Address]; ok {
+			return nil, fmt.Errorf("vault: %s is configured more than once", v.Address)
+		}
+		registry[v.Address] = v
+	}
+
+	return &Registry{
+		vaults: registry,
+	}, nil
+}
+
+// Get returns the vault at address, if it is one of the configured vaults.
+func (r *Registry) Get(address string) (Vault, bool) {
+	v, ok := r.vaults[ethutils.ChecksumAddress(address)]
+	return v, ok
+}
+
+func (r *Registry) All() []Vault {
+	vaults := make([]Vault, 0, len(r.vaults))
+	for _, v := range r.vaults {
+		vaults = append(vaults, v)
+	}
+	return vaults
+}
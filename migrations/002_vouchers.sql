@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS vouchers (
+  id INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  tx_hash VARCHAR(66) NOT NULL,
+  log_index INT NOT NULL,
+  sender_address VARCHAR(42) NOT NULL,
+  recipient_address VARCHAR(42) NOT NULL,
+  contract_address VARCHAR(42) NOT NULL,
+  transfer_value NUMERIC NOT NULL,
+  amount TEXT NOT NULL,
+  token_symbol TEXT NOT NULL,
+  status TEXT NOT NULL DEFAULT 'pending',
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  UNIQUE (tx_hash, log_index)
+);
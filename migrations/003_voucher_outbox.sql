@@ -0,0 +1,6 @@
+-- Vouchers are queued in an outbox and issued by a worker with retries
+ALTER TABLE vouchers ADD COLUMN IF NOT EXISTS attempts INT NOT NULL DEFAULT 0;
+ALTER TABLE vouchers ADD COLUMN IF NOT EXISTS next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW();
+ALTER TABLE vouchers ADD COLUMN IF NOT EXISTS last_error TEXT;
+
+CREATE INDEX IF NOT EXISTS vouchers_pending_idx ON vouchers (next_attempt_at) WHERE status = 'pending';
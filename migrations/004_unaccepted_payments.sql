@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS unaccepted_payments (
+  id INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  tx_id INT REFERENCES tx(id),
+  log_index INT NOT NULL,
+  sender_address VARCHAR(42) NOT NULL,
+  recipient_address VARCHAR(42) NOT NULL,
+  contract_address VARCHAR(42) NOT NULL,
+  transfer_value NUMERIC NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  UNIQUE (tx_id, log_index)
+);
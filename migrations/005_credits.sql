@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS credits (
+  id INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  sender_address VARCHAR(42) NOT NULL,
+  amount NUMERIC NOT NULL,
+  kind TEXT NOT NULL,
+  tx_hash VARCHAR(66) NOT NULL,
+  log_index INT NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  UNIQUE (tx_hash, log_index, kind)
+);
+
+CREATE INDEX IF NOT EXISTS credits_sender_address_idx ON credits (sender_address);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS partial_payments (
+  id INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  sender_address VARCHAR(42) NOT NULL,
+  amount NUMERIC NOT NULL,
+  tx_hash VARCHAR(66) NOT NULL,
+  log_index INT NOT NULL,
+  expires_at TIMESTAMP NOT NULL,
+  settled_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  UNIQUE (tx_hash, log_index)
+);
+
+CREATE INDEX IF NOT EXISTS partial_payments_open_idx ON partial_payments (sender_address) WHERE settled_at IS NULL;
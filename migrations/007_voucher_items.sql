@@ -0,0 +1,12 @@
+-- A purchase may buy several vouchers, each issued on its own
+CREATE TABLE IF NOT EXISTS voucher_items (
+  id INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  voucher_id INT NOT NULL REFERENCES vouchers(id),
+  seq INT NOT NULL,
+  tier TEXT NOT NULL,
+  profile_pk INT NOT NULL,
+  tier_description TEXT NOT NULL,
+  voucher_code TEXT,
+  issued_at TIMESTAMP,
+  UNIQUE (voucher_id, seq)
+);
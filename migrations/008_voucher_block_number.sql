@@ -0,0 +1 @@
+ALTER TABLE vouchers ADD COLUMN IF NOT EXISTS block_number BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,7 @@
+ALTER TABLE token_transfer ADD COLUMN IF NOT EXISTS tenant_id TEXT;
+ALTER TABLE vouchers ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE credits ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE partial_payments ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT 'default';
+
+DROP INDEX IF EXISTS credits_sender_address_idx;
+CREATE INDEX IF NOT EXISTS credits_tenant_sender_address_idx ON credits (tenant_id, sender_address);
@@ -0,0 +1,17 @@
+ALTER TABLE voucher_items ADD COLUMN IF NOT EXISTS subscription_days INT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS subscriptions (
+  id INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  tenant_id TEXT NOT NULL,
+  vault_address VARCHAR(42) NOT NULL,
+  sender_address VARCHAR(42) NOT NULL,
+  tier TEXT NOT NULL,
+  tier_description TEXT NOT NULL,
+  starts_at TIMESTAMP NOT NULL,
+  ends_at TIMESTAMP NOT NULL,
+  reminded_at TIMESTAMP,
+  updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  UNIQUE (tenant_id, sender_address, tier)
+);
+
+CREATE INDEX IF NOT EXISTS subscriptions_ends_at_idx ON subscriptions (ends_at) WHERE reminded_at IS NULL;
@@ -0,0 +1,19 @@
+ALTER TABLE vouchers ADD COLUMN IF NOT EXISTS price NUMERIC NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS vouchers_tenant_sender_created_idx ON vouchers (tenant_id, sender_address, created_at);
+CREATE INDEX IF NOT EXISTS vouchers_tenant_created_idx ON vouchers (tenant_id, created_at);
+
+-- Purchases that broke a limit rule, their voucher stays in held until reviewed
+CREATE TABLE IF NOT EXISTS review_queue (
+  id INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  voucher_id INT NOT NULL REFERENCES vouchers(id),
+  tenant_id TEXT NOT NULL,
+  sender_address VARCHAR(42) NOT NULL,
+  rule TEXT NOT NULL,
+  reason TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  resolved_at TIMESTAMP,
+  UNIQUE (voucher_id)
+);
+
+CREATE INDEX IF NOT EXISTS review_queue_open_idx ON review_queue (tenant_id) WHERE resolved_at IS NULL;
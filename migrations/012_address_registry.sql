@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS address_registry (
+  tenant_id TEXT NOT NULL,
+  address VARCHAR(42) NOT NULL,
+  allowed BOOLEAN NOT NULL DEFAULT false,
+  blocked BOOLEAN NOT NULL DEFAULT false,
+  member BOOLEAN NOT NULL DEFAULT false,
+  pricing_group TEXT NOT NULL DEFAULT '',
+  note TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (tenant_id, address)
+);
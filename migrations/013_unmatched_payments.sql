@@ -0,0 +1,23 @@
+-- Accepted payments to a vault that bought nothing, kept until an admin resolves them
+CREATE TABLE IF NOT EXISTS unmatched_payments (
+  id INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  tenant_id TEXT NOT NULL,
+  tx_hash VARCHAR(66) NOT NULL,
+  log_index INT NOT NULL,
+  sender_address VARCHAR(42) NOT NULL,
+  recipient_address VARCHAR(42) NOT NULL,
+  contract_address VARCHAR(42) NOT NULL,
+  transfer_value NUMERIC NOT NULL,
+  token_amount TEXT NOT NULL,
+  token_symbol TEXT NOT NULL,
+  amount NUMERIC NOT NULL,
+  block_number BIGINT NOT NULL,
+  reason TEXT NOT NULL,
+  status TEXT NOT NULL DEFAULT 'open',
+  resolution_note TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  resolved_at TIMESTAMP,
+  UNIQUE (tx_hash, log_index)
+);
+
+CREATE INDEX IF NOT EXISTS unmatched_payments_open_idx ON unmatched_payments (tenant_id) WHERE status = 'open';
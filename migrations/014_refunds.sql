@@ -0,0 +1,22 @@
+-- ERC20 transfers from a vault back to the sender of a payment that could not be turned into vouchers
+CREATE TABLE IF NOT EXISTS refunds (
+  id INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  tenant_id TEXT NOT NULL,
+  source TEXT NOT NULL,
+  tx_hash VARCHAR(66) NOT NULL,
+  log_index INT NOT NULL,
+  vault_address VARCHAR(42) NOT NULL,
+  sender_address VARCHAR(42) NOT NULL,
+  contract_address VARCHAR(42) NOT NULL,
+  value NUMERIC NOT NULL,
+  status TEXT NOT NULL DEFAULT 'pending',
+  refund_tx_hash VARCHAR(66),
+  raw_tx BYTEA,
+  attempts INT NOT NULL DEFAULT 0,
+  last_error TEXT,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  UNIQUE (tx_hash, log_index)
+);
+
+CREATE INDEX IF NOT EXISTS refunds_open_idx ON refunds (status) WHERE status IN ('pending', 'sent');
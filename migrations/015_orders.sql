@@ -0,0 +1,20 @@
+-- Orders reserve a unique transfer value per vault and token, so that a payment identifies the tier it buys
+CREATE TABLE IF NOT EXISTS orders (
+  id TEXT PRIMARY KEY,
+  tenant_id TEXT NOT NULL,
+  vault_address VARCHAR(42) NOT NULL,
+  tier TEXT NOT NULL,
+  tier_description TEXT NOT NULL,
+  contract_address VARCHAR(42) NOT NULL,
+  token_symbol TEXT NOT NULL,
+  token_amount TEXT NOT NULL,
+  transfer_value NUMERIC NOT NULL,
+  status TEXT NOT NULL DEFAULT 'open',
+  tx_hash VARCHAR(66),
+  log_index INT,
+  expires_at TIMESTAMP NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  paid_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS orders_open_value_idx ON orders (vault_address, contract_address, transfer_value) WHERE status = 'open';
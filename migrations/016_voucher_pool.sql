@@ -0,0 +1,13 @@
+-- Pre-generated voucher codes imported per vault and RadiusDesk profile, issued when the provider can not be used
+CREATE TABLE IF NOT EXISTS voucher_pool (
+  id SERIAL PRIMARY KEY,
+  vault_address VARCHAR(42) NOT NULL,
+  profile_pk INT NOT NULL,
+  code TEXT NOT NULL,
+  voucher_item_id INT REFERENCES voucher_items(id) UNIQUE,
+  imported_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  claimed_at TIMESTAMP,
+  UNIQUE (vault_address, code)
+);
+
+CREATE INDEX IF NOT EXISTS voucher_pool_available_idx ON voucher_pool (vault_address, profile_pk) WHERE voucher_item_id IS NULL;
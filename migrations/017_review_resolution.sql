@@ -0,0 +1,6 @@
+-- Held purchases are released to the outbox or rejected by an admin
+ALTER TABLE review_queue ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'open';
+ALTER TABLE review_queue ADD COLUMN IF NOT EXISTS resolution_note TEXT NOT NULL DEFAULT '';
+
+DROP INDEX IF EXISTS review_queue_open_idx;
+CREATE INDEX IF NOT EXISTS review_queue_tenant_status_idx ON review_queue (tenant_id, status);
@@ -0,0 +1,5 @@
+-- A refunded purchase gives back the partial payments it settled
+ALTER TABLE partial_payments ADD COLUMN IF NOT EXISTS settled_tx_hash VARCHAR(66);
+ALTER TABLE partial_payments ADD COLUMN IF NOT EXISTS settled_log_index INT;
+
+CREATE INDEX IF NOT EXISTS partial_payments_settled_idx ON partial_payments (settled_tx_hash, settled_log_index) WHERE settled_tx_hash IS NOT NULL;
@@ -0,0 +1,11 @@
+-- The voucher pool key is an identity column like the other tables, continuing from the codes already imported
+DO $$
+BEGIN
+  IF NOT EXISTS (SELECT 1 FROM pg_attribute WHERE attrelid = 'voucher_pool'::regclass AND attname = 'id' AND attidentity <> '') THEN
+    ALTER TABLE voucher_pool ALTER COLUMN id DROP DEFAULT;
+    DROP SEQUENCE IF EXISTS voucher_pool_id_seq;
+    ALTER TABLE voucher_pool ALTER COLUMN id ADD GENERATED ALWAYS AS IDENTITY;
+    PERFORM setval(pg_get_serial_sequence('voucher_pool', 'id'), COALESCE((SELECT MAX(id) FROM voucher_pool), 0) + 1, false);
+  END IF;
+END
+$$;
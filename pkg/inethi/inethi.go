@@ -4,12 +4,18 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
 )
 
+// ErrNotFound is returned when iNethi does not know the requested voucher.
+var ErrNotFound = errors.New("iNethi: not found")
+
 type (
 	InethiClient struct {
 		apiKey     string
@@ -17,7 +23,15 @@ type (
 		httpClient *http.Client
 	}
 
+	// Site identifies the RadiusDesk instance, cloud and realm a voucher is created in.
+	Site struct {
+		InstancePK int
+		CloudPK    int
+		RealmPK    int
+	}
+
 	VoucherPayload struct {
+		Site             Site
 		SenderAddress    string
 		RecipientAddress string
 		Amount           string
@@ -28,16 +42,59 @@ type (
 	VoucherResponse struct {
 		Voucher string `json:"voucher"`
 	}
+
+	// Voucher is a voucher generated on iNethi.
+	Voucher struct {
+		Voucher          string    `json:"voucher"`
+		SenderAddress    string    `json:"sender_address"`
+		RecipientAddress string    `json:"recipient_address"`
+		Amount           string    `json:"amount"`
+		Token            string    `json:"token"`
+		ProfilePK        int       `json:"radius_desk_profile_pk"`
+		CreatedAt        time.Time `json:"created_at"`
+	}
+
+	// VoucherStatus is the state of a voucher on RadiusDesk and what it has been used for so far. DataUsed is in
+	// bytes, TimeUsed in seconds.
+	VoucherStatus struct {
+		Voucher  string     `json:"voucher"`
+		Status   string     `json:"status"`
+		DataUsed int64      `json:"data_used"`
+		TimeUsed int64      `json:"time_used"`
+		LastSeen *time.Time `json:"last_seen"`
+	}
+
+	// Profile is a RadiusDesk profile of a site, the limits a voucher is created with.
+	Profile struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
 )
 
-func New(apiKey string, endpoint string) *InethiClient {
+// StatusError is returned for a response with an error status other than 404.
+type StatusError struct {
+	StatusCode int
+	Status     string
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("iNethi server error: code=%s: response_body=%s", e.Status, e.Body)
+}
+
+// New creates an iNethi client sending its requests through transport, http.DefaultTransport when nil.
+func New(apiKey string, endpoint string, transport http.RoundTripper) *InethiClient {
 	iClient := &InethiClient{
 		apiKey:   apiKey,
 		endpoint: endpoint,
 		httpClient: &http.Client{
-			Timeout: time.Second * 10,
+			Transport: transport,
 		},
 	}
+	// The transport bounds each attempt, a client timeout would cut its retries short.
+	if transport == nil {
+		iClient.httpClient.Timeout = time.Second * 10
+	}
 
 	return iClient
 }
@@ -59,6 +116,24 @@ func (i *InethiClient) postRequestWithCtx(ctx context.Context, url string, body
 	return i.do(req)
 }
 
+func (i *InethiClient) getRequestWithCtx(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return i.do(req)
+}
+
+func (i *InethiClient) deleteRequestWithCtx(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return i.do(req)
+}
+
 func (i *InethiClient) do(req *http.Request) (*http.Response, error) {
 	return i.httpClient.Do(i.setDefaultHeaders(req))
 }
@@ -66,15 +141,25 @@ func (i *InethiClient) do(req *http.Request) (*http.Response, error) {
 func parseResponse(resp *http.Response, target interface{}) error {
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
 	if resp.StatusCode >= http.StatusBadRequest {
 		b, err := io.ReadAll(resp.Body)
 		if err != nil {
 			return err
 		}
 
-		return fmt.Errorf("iNethi server error: code=%s: response_body=%s", resp.Status, string(b))
+		return &StatusError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Body:       string(b),
+		}
 	}
 
+	if target == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
 	return json.NewDecoder(resp.Body).Decode(target)
 }
 
@@ -95,10 +180,10 @@ func (i *InethiClient) GenerateVoucher(ctx context.Context, input VoucherPayload
 		Category             string `json:"category"`
 		Token                string `json:"token"`
 	}{
-		RadiusDeskInstancePK: 3,
+		RadiusDeskInstancePK: input.Site.InstancePK,
 		RadiusDeskProfilePK:  input.CouponSize,
-		RadiusDeskCloudPK:    3,
-		RadiusDeskRealmPK:    3,
+		RadiusDeskCloudPK:    input.Site.CloudPK,
+		RadiusDeskRealmPK:    input.Site.RealmPK,
 		SenderAddress:        input.SenderAddress,
 		RecipientAddress:     input.RecipientAddress,
 		Amount:               input.Amount,
@@ -119,3 +204,83 @@ func (i *InethiClient) GenerateVoucher(ctx context.Context, input VoucherPayload
 
 	return voucherResponse, nil
 }
+
+// The calls below are experimental. Only add_voucher is a documented iNethi endpoint, the paths and response
+// shapes of the others follow its conventions and are checked against the fake server in inethi_test.go, not against
+// a captured iNethi response. They may change once iNethi publishes them.
+
+// ListVouchers returns the vouchers generated for purchases by a sender. Experimental.
+func (i *InethiClient) ListVouchers(ctx context.Context, senderAddress string) ([]Voucher, error) {
+	var vouchers []Voucher
+
+	query := url.Values{"sender_address": {senderAddress}}
+	resp, err := i.getRequestWithCtx(ctx, i.endpoint+"/api/v1/vouchers/?"+query.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := parseResponse(resp, &vouchers); err != nil {
+		return nil, err
+	}
+
+	return vouchers, nil
+}
+
+// VoucherStatus returns whether a voucher is still valid and how much of it was used. Experimental.
+func (i *InethiClient) VoucherStatus(ctx context.Context, voucher string) (VoucherStatus, error) {
+	var status VoucherStatus
+
+	resp, err := i.getRequestWithCtx(ctx, i.endpoint+"/api/v1/vouchers/"+url.PathEscape(voucher)+"/status/")
+	if err != nil {
+		return status, err
+	}
+
+	if err := parseResponse(resp, &status); err != nil {
+		return status, err
+	}
+
+	return status, nil
+}
+
+// RevokeVoucher disables a voucher on RadiusDesk. The voucher is kept so that its status can still be read.
+// Experimental.
+func (i *InethiClient) RevokeVoucher(ctx context.Context, voucher string) error {
+	resp, err := i.postRequestWithCtx(ctx, i.endpoint+"/api/v1/vouchers/"+url.PathEscape(voucher)+"/revoke/", nil)
+	if err != nil {
+		return err
+	}
+
+	return parseResponse(resp, nil)
+}
+
+// DeleteVoucher removes a voucher from iNethi and RadiusDesk. Experimental.
+func (i *InethiClient) DeleteVoucher(ctx context.Context, voucher string) error {
+	resp, err := i.deleteRequestWithCtx(ctx, i.endpoint+"/api/v1/vouchers/"+url.PathEscape(voucher)+"/")
+	if err != nil {
+		return err
+	}
+
+	return parseResponse(resp, nil)
+}
+
+// ListProfiles returns the RadiusDesk profiles available on a site. Experimental, the profile sync keeps the
+// configured profile pks when it fails.
+func (i *InethiClient) ListProfiles(ctx context.Context, site Site) ([]Profile, error) {
+	var profiles []Profile
+
+	query := url.Values{
+		"radius_desk_instance_pk": {strconv.Itoa(site.InstancePK)},
+		"radius_desk_cloud_pk":    {strconv.Itoa(site.CloudPK)},
+		"radius_desk_realm_pk":    {strconv.Itoa(site.RealmPK)},
+	}
+	resp, err := i.getRequestWithCtx(ctx, i.endpoint+"/api/v1/radiusdesk/profiles/?"+query.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := parseResponse(resp, &profiles); err != nil {
+		return nil, err
+	}
+
+	return profiles, nil
+}
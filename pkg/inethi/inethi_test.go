@@ -0,0 +1,155 @@
+package inethi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const testAPIKey = "test-key"
+
+// fakeInethi serves handler behind the API key check of iNethi.
+func fakeInethi(t *testing.T, handler http.HandlerFunc) *InethiClient {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "ApiKey "+testAPIKey {
+			t.Errorf("Authorization = %q, want %q", got, "ApiKey "+testAPIKey)
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	return New(testAPIKey, srv.URL, nil)
+}
+
+func writeJSON(t *testing.T, w http.ResponseWriter, v any) {
+	t.Helper()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestListVouchers(t *testing.T) {
+	client := fakeInethi(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/api/v1/vouchers/" {
+			t.Errorf("request = %s %s, want GET /api/v1/vouchers/", r.Method, r.URL.Path)
+		}
+		if got := r.URL.Query().Get("sender_address"); got != "0xSender" {
+			t.Errorf("sender_address = %q, want 0xSender", got)
+		}
+		writeJSON(t, w, []map[string]any{
+			{"voucher": "AAAA", "sender_address": "0xSender", "amount": "10", "token": "cUSD", "radius_desk_profile_pk": 7},
+			{"voucher": "BBBB", "sender_address": "0xSender", "amount": "20", "token": "cUSD", "radius_desk_profile_pk": 8},
+		})
+	})
+
+	vouchers, err := client.ListVouchers(context.Background(), "0xSender")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vouchers) != 2 {
+		t.Fatalf("got %d vouchers, want 2", len(vouchers))
+	}
+	if vouchers[0].Voucher != "AAAA" || vouchers[0].ProfilePK != 7 || vouchers[1].Amount != "20" {
+		t.Errorf("unexpected vouchers %+v", vouchers)
+	}
+}
+
+func TestVoucherStatus(t *testing.T) {
+	client := fakeInethi(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/vouchers/AAAA/status/":
+			writeJSON(t, w, map[string]any{"voucher": "AAAA", "status": "used", "data_used": 1024, "time_used": 60})
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	status, err := client.VoucherStatus(context.Background(), "AAAA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Status != "used" || status.DataUsed != 1024 || status.TimeUsed != 60 {
+		t.Errorf("unexpected status %+v", status)
+	}
+
+	if _, err := client.VoucherStatus(context.Background(), "ZZZZ"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unknown voucher error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestRevokeVoucher(t *testing.T) {
+	var revoked bool
+	client := fakeInethi(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v1/vouchers/AAAA/revoke/" {
+			t.Errorf("request = %s %s, want POST /api/v1/vouchers/AAAA/revoke/", r.Method, r.URL.Path)
+		}
+		revoked = true
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	if err := client.RevokeVoucher(context.Background(), "AAAA"); err != nil {
+		t.Fatal(err)
+	}
+	if !revoked {
+		t.Error("revoke request not sent")
+	}
+}
+
+func TestDeleteVoucher(t *testing.T) {
+	client := fakeInethi(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			t.Errorf("method = %s, want DELETE", r.Method)
+		}
+		switch r.URL.Path {
+		case "/api/v1/vouchers/AAAA/":
+			w.WriteHeader(http.StatusNoContent)
+		case "/api/v1/vouchers/BBBB/":
+			http.Error(w, "boom", http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	if err := client.DeleteVoucher(context.Background(), "AAAA"); err != nil {
+		t.Fatal(err)
+	}
+
+	err := client.DeleteVoucher(context.Background(), "BBBB")
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusInternalServerError {
+		t.Errorf("server error = %v, want StatusError with status 500", err)
+	}
+
+	if err := client.DeleteVoucher(context.Background(), "ZZZZ"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unknown voucher error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestListProfiles(t *testing.T) {
+	site := Site{InstancePK: 1, CloudPK: 2, RealmPK: 3}
+	client := fakeInethi(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/api/v1/radiusdesk/profiles/" {
+			t.Errorf("request = %s %s, want GET /api/v1/radiusdesk/profiles/", r.Method, r.URL.Path)
+		}
+		query := r.URL.Query()
+		if query.Get("radius_desk_instance_pk") != "1" || query.Get("radius_desk_cloud_pk") != "2" || query.Get("radius_desk_realm_pk") != "3" {
+			t.Errorf("unexpected site query %s", r.URL.RawQuery)
+		}
+		writeJSON(t, w, []Profile{{ID: 7, Name: "1h"}, {ID: 8, Name: "1d"}})
+	})
+
+	profiles, err := client.ListProfiles(context.Background(), site)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(profiles) != 2 || profiles[0] != (Profile{ID: 7, Name: "1h"}) || profiles[1] != (Profile{ID: 8, Name: "1d"}) {
+		t.Errorf("unexpected profiles %+v", profiles)
+	}
+}
@@ -19,8 +19,25 @@ type (
 
 	NotifyPayload struct {
 		SenderAddress string `json:"senderAddress"`
-		Code          string `json:"code"`
-		Size          string `json:"size"`
+		// Code is the first of Codes, kept for receivers that only handle a single voucher.
+		Code  string   `json:"code"`
+		Codes []string `json:"codes"`
+		Size  string   `json:"size"`
+	}
+
+	// ReminderPayload tells a subscriber that their subscription is about to expire.
+	ReminderPayload struct {
+		SenderAddress string    `json:"senderAddress"`
+		Size          string    `json:"size"`
+		ExpiresAt     time.Time `json:"expiresAt"`
+	}
+
+	// PaymentUpdatePayload explains to a sender what happened to a payment that did not buy a voucher.
+	PaymentUpdatePayload struct {
+		SenderAddress string `json:"senderAddress"`
+		TxHash        string `json:"txHash"`
+		Status        string `json:"status"`
+		Message       string `json:"message"`
 	}
 
 	NotifyResponse struct {
@@ -29,14 +46,19 @@ type (
 	}
 )
 
-func New(bearerToken string, endpoint string) *NotifyClient {
+// New creates a notify client sending its requests through transport, http.DefaultTransport when nil.
+func New(bearerToken string, endpoint string, transport http.RoundTripper) *NotifyClient {
 	nClient := &NotifyClient{
 		bearerToken: bearerToken,
 		endpoint:    endpoint,
 		httpClient: &http.Client{
-			Timeout: time.Second * 10,
+			Transport: transport,
 		},
 	}
+	// The transport bounds each attempt, a client timeout would cut its retries short.
+	if transport == nil {
+		nClient.httpClient.Timeout = time.Second * 10
+	}
 
 	return nClient
 }
@@ -98,3 +120,47 @@ func (n *NotifyClient) SendNotification(ctx context.Context, input NotifyPayload
 
 	return notifyResponse, nil
 }
+
+func (n *NotifyClient) SendReminder(ctx context.Context, input ReminderPayload) (NotifyResponse, error) {
+	var (
+		buf            bytes.Buffer
+		notifyResponse NotifyResponse
+	)
+
+	if err := json.NewEncoder(&buf).Encode(input); err != nil {
+		return notifyResponse, err
+	}
+
+	resp, err := n.postRequestWithCtx(ctx, n.endpoint+"/api/v1/external/inethi/reminder", &buf)
+	if err != nil {
+		return notifyResponse, err
+	}
+
+	if err := parseResponse(resp, &notifyResponse); err != nil {
+		return notifyResponse, err
+	}
+
+	return notifyResponse, nil
+}
+
+func (n *NotifyClient) SendPaymentUpdate(ctx context.Context, input PaymentUpdatePayload) (NotifyResponse, error) {
+	var (
+		buf            bytes.Buffer
+		notifyResponse NotifyResponse
+	)
+
+	if err := json.NewEncoder(&buf).Encode(input); err != nil {
+		return notifyResponse, err
+	}
+
+	resp, err := n.postRequestWithCtx(ctx, n.endpoint+"/api/v1/external/inethi/payment", &buf)
+	if err != nil {
+		return notifyResponse, err
+	}
+
+	if err := parseResponse(resp, &notifyResponse); err != nil {
+		return notifyResponse, err
+	}
+
+	return notifyResponse, nil
+}
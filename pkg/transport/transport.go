@@ -0,0 +1,280 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"golang.org/x/time/rate"
+)
+
+const (
+	breakerClosed = iota
+	breakerHalfOpen
+	breakerOpen
+)
+
+// ErrCircuitOpen is returned without sending the request while the breaker of an endpoint is open.
+var ErrCircuitOpen = errors.New("transport: circuit breaker open")
+
+type (
+	// Opts configures a Transport. Zero values disable the matching feature: no retries, no circuit breaker or
+	// no rate limit.
+	Opts struct {
+		// Base sends the requests, http.DefaultTransport when nil.
+		Base http.RoundTripper
+		// MaxRetries is how often a failed idempotent request is retried.
+		MaxRetries  int
+		BaseBackoff time.Duration
+		MaxBackoff  time.Duration
+		// AttemptTimeout bounds every attempt, including reading its response body, so that a hanging attempt is
+		// retried instead of using up the whole request.
+		AttemptTimeout time.Duration
+		// BreakerThreshold is the number of consecutive failures after which an endpoint's breaker opens.
+		BreakerThreshold int
+		// BreakerCooldown is how long an open breaker rejects requests before letting a trial request through.
+		BreakerCooldown time.Duration
+		// Rate is the number of requests per second sent to an endpoint, with bursts of up to Burst requests.
+		Rate  float64
+		Burst int
+	}
+
+	// Transport is an http.RoundTripper shared by the outbound API clients. Every endpoint, identified by its
+	// host, gets its own circuit breaker and token bucket.
+	Transport struct {
+		opts      Opts
+		mu        sync.Mutex
+		endpoints map[string]*endpoint
+	}
+
+	endpoint struct {
+		host    string
+		limiter *rate.Limiter
+
+		mu       sync.Mutex
+		state    int
+		failures int
+		openedAt time.Time
+		trial    bool
+	}
+)
+
+func New(o Opts) *Transport {
+	if o.Base == nil {
+		o.Base = http.DefaultTransport
+	}
+
+	return &Transport{
+		opts:      o,
+		endpoints: make(map[string]*endpoint),
+	}
+}
+
+// RoundTrip sends a request through the endpoint's rate limit and circuit breaker. Network errors, timed out
+// attempts, 429 and 5xx responses count as failures; idempotent requests are retried on them with jittered
+// exponential backoff. A POST is only idempotent with an Idempotency-Key header, none of the APIs called through
+// here support one, so POSTs are only retried when the connection could not be established and never reach the
+// server twice.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	e := t.endpoint(req.URL.Host)
+
+	for attempt := 0; ; attempt++ {
+		// The rate limit is waited for first, a request given up while waiting must not hold the half open
+		// breaker's trial slot.
+		if e.limiter != nil {
+			if err := e.limiter.Wait(req.Context()); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := e.allow(t.opts); err != nil {
+			metrics.GetOrCreateCounter(fmt.Sprintf(`http_client_rejected_total{host=%q}`, e.host)).Inc()
+			return nil, err
+		}
+
+		resp, err := t.send(req, attempt)
+		failed := err != nil || retryableStatus(resp.StatusCode)
+
+		metrics.GetOrCreateCounter(fmt.Sprintf(`http_client_requests_total{host=%q}`, e.host)).Inc()
+		if failed {
+			metrics.GetOrCreateCounter(fmt.Sprintf(`http_client_errors_total{host=%q}`, e.host)).Inc()
+		}
+		e.record(t.opts, failed)
+
+		if !failed || attempt >= t.opts.MaxRetries || !(retryable(req) || notSent(err)) {
+			return resp, err
+		}
+
+		if resp != nil {
+			resp.Body.Close()
+		}
+		metrics.GetOrCreateCounter(fmt.Sprintf(`http_client_retries_total{host=%q}`, e.host)).Inc()
+
+		timer := time.NewTimer(t.backoff(attempt))
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// send issues one attempt within AttemptTimeout, rewinding the request body for retries. The attempt's context
+// is released once the response body is closed.
+func (t *Transport) send(req *http.Request, attempt int) (*http.Response, error) {
+	ctx, cancel := req.Context(), context.CancelFunc(func() {})
+	if t.opts.AttemptTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, t.opts.AttemptTimeout)
+	}
+
+	if attempt > 0 && req.Body != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		req = req.Clone(ctx)
+		req.Body = body
+	} else if t.opts.AttemptTimeout > 0 {
+		req = req.WithContext(ctx)
+	}
+
+	resp, err := t.opts.Base.RoundTrip(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelBody releases the context of an attempt when its response body is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+func (t *Transport) endpoint(host string) *endpoint {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e, ok := t.endpoints[host]
+	if !ok {
+		e = &endpoint{host: host}
+		if t.opts.Rate > 0 {
+			e.limiter = rate.NewLimiter(rate.Limit(t.opts.Rate), max(t.opts.Burst, 1))
+		}
+		t.endpoints[host] = e
+
+		metrics.GetOrCreateGauge(fmt.Sprintf(`http_client_breaker_state{host=%q}`, host), func() float64 {
+			e.mu.Lock()
+			defer e.mu.Unlock()
+			return float64(e.state)
+		})
+	}
+	return e
+}
+
+// backoff returns a random delay of up to BaseBackoff doubled per attempt, capped at MaxBackoff.
+func (t *Transport) backoff(attempt int) time.Duration {
+	if t.opts.BaseBackoff <= 0 {
+		return 0
+	}
+
+	ceiling := t.opts.BaseBackoff << attempt
+	if t.opts.MaxBackoff > 0 && (ceiling > t.opts.MaxBackoff || ceiling <= 0) {
+		ceiling = t.opts.MaxBackoff
+	}
+	return time.Duration(rand.Int64N(int64(ceiling)) + 1)
+}
+
+// allow rejects requests while the breaker is open. Once the cooldown has passed, a single trial request is let
+// through in the half open state.
+func (e *endpoint) allow(o Opts) error {
+	if o.BreakerThreshold <= 0 {
+		return nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	switch e.state {
+	case breakerOpen:
+		if time.Since(e.openedAt) < o.BreakerCooldown {
+			return fmt.Errorf("%w: %s", ErrCircuitOpen, e.host)
+		}
+		e.state = breakerHalfOpen
+		e.trial = true
+		return nil
+	case breakerHalfOpen:
+		if e.trial {
+			return fmt.Errorf("%w: %s", ErrCircuitOpen, e.host)
+		}
+		e.trial = true
+	}
+	return nil
+}
+
+// record updates the breaker with the outcome of a request. A failed trial request opens the breaker again.
+func (e *endpoint) record(o Opts, failed bool) {
+	if o.BreakerThreshold <= 0 {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.trial = false
+	if !failed {
+		e.state = breakerClosed
+		e.failures = 0
+		return
+	}
+
+	e.failures++
+	if e.state == breakerHalfOpen || e.failures >= o.BreakerThreshold {
+		if e.state != breakerOpen {
+			metrics.GetOrCreateCounter(fmt.Sprintf(`http_client_breaker_opened_total{host=%q}`, e.host)).Inc()
+		}
+		e.state = breakerOpen
+		e.openedAt = time.Now()
+	}
+}
+
+// retryable reports whether a request may be sent again without side effects.
+func retryable(req *http.Request) bool {
+	if req.Body != nil && req.GetBody == nil {
+		return false
+	}
+
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return req.Header.Get("Idempotency-Key") != ""
+	}
+}
+
+// notSent reports whether err happened while dialing, before any part of the request was written.
+func notSent(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
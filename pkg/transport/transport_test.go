@@ -0,0 +1,244 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeServer answers every request with the status returned by status, called with the 1-based request count.
+func fakeServer(t *testing.T, status func(n int64) int) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+
+	var hits atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(status(hits.Add(1)))
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, &hits
+}
+
+func do(t *testing.T, client *http.Client, ctx context.Context, method string, url string) (*http.Response, error) {
+	t.Helper()
+
+	var body io.Reader
+	if method == http.MethodPost {
+		body = strings.NewReader(`{"voucher":"AAAA"}`)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Do(req)
+	if resp != nil {
+		resp.Body.Close()
+	}
+	return resp, err
+}
+
+func TestRetriesServerErrors(t *testing.T) {
+	for _, failure := range []int{http.StatusServiceUnavailable, http.StatusTooManyRequests} {
+		srv, hits := fakeServer(t, func(n int64) int {
+			if n <= 2 {
+				return failure
+			}
+			return http.StatusOK
+		})
+		client := &http.Client{Transport: New(Opts{
+			MaxRetries:  3,
+			BaseBackoff: time.Millisecond,
+			MaxBackoff:  time.Millisecond * 5,
+		})}
+
+		resp, err := do(t, client, context.Background(), http.MethodGet, srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("%d: status = %d, want 200", failure, resp.StatusCode)
+		}
+		if got := hits.Load(); got != 3 {
+			t.Errorf("%d: server hit %d times, want 3", failure, got)
+		}
+	}
+}
+
+func TestDoesNotRetryPost(t *testing.T) {
+	srv, hits := fakeServer(t, func(int64) int {
+		return http.StatusInternalServerError
+	})
+	client := &http.Client{Transport: New(Opts{
+		MaxRetries:  3,
+		BaseBackoff: time.Millisecond,
+	})}
+
+	resp, err := do(t, client, context.Background(), http.MethodPost, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", resp.StatusCode)
+	}
+	if got := hits.Load(); got != 1 {
+		t.Errorf("server hit %d times, want 1", got)
+	}
+}
+
+func TestRetriesTimedOutAttempts(t *testing.T) {
+	var hits atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if hits.Add(1) == 1 {
+			time.Sleep(time.Millisecond * 200)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(srv.Close)
+
+	client := &http.Client{Transport: New(Opts{
+		MaxRetries:     1,
+		AttemptTimeout: time.Millisecond * 50,
+		BaseBackoff:    time.Millisecond,
+	})}
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading the body of the retried attempt: %v", err)
+	}
+	if string(body) != "ok" {
+		t.Errorf("body = %q, want ok", body)
+	}
+	if got := hits.Load(); got != 2 {
+		t.Errorf("server hit %d times, want 2", got)
+	}
+
+	hits.Store(0)
+	if _, err := do(t, client, context.Background(), http.MethodPost, srv.URL); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("timed out POST error = %v, want DeadlineExceeded", err)
+	}
+	if got := hits.Load(); got != 1 {
+		t.Errorf("timed out POST sent %d times, want 1", got)
+	}
+}
+
+func TestBreakerOpensAndRecovers(t *testing.T) {
+	var healthy atomic.Bool
+	srv, hits := fakeServer(t, func(int64) int {
+		if healthy.Load() {
+			return http.StatusOK
+		}
+		return http.StatusInternalServerError
+	})
+	tr := New(Opts{
+		BreakerThreshold: 2,
+		BreakerCooldown:  time.Millisecond * 50,
+	})
+	client := &http.Client{Transport: tr}
+
+	for range 2 {
+		if _, err := do(t, client, context.Background(), http.MethodGet, srv.URL); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if state := breakerState(tr, srv); state != breakerOpen {
+		t.Fatalf("breaker state = %d after %d failures, want open", state, hits.Load())
+	}
+
+	if _, err := do(t, client, context.Background(), http.MethodGet, srv.URL); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("open breaker error = %v, want ErrCircuitOpen", err)
+	}
+	if got := hits.Load(); got != 2 {
+		t.Errorf("open breaker let a request through, server hit %d times", got)
+	}
+
+	// A failed trial opens the breaker again.
+	time.Sleep(time.Millisecond * 60)
+	if _, err := do(t, client, context.Background(), http.MethodGet, srv.URL); err != nil {
+		t.Fatal(err)
+	}
+	if state := breakerState(tr, srv); state != breakerOpen {
+		t.Fatalf("breaker state = %d after a failed trial, want open", state)
+	}
+
+	healthy.Store(true)
+	time.Sleep(time.Millisecond * 60)
+	resp, err := do(t, client, context.Background(), http.MethodGet, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("trial status = %d, want 200", resp.StatusCode)
+	}
+	if state := breakerState(tr, srv); state != breakerClosed {
+		t.Fatalf("breaker state = %d after a successful trial, want closed", state)
+	}
+
+	if _, err := do(t, client, context.Background(), http.MethodGet, srv.URL); err != nil {
+		t.Errorf("closed breaker error = %v", err)
+	}
+}
+
+func TestCancelledRateLimitWaitKeepsTrial(t *testing.T) {
+	var healthy atomic.Bool
+	srv, _ := fakeServer(t, func(int64) int {
+		if healthy.Load() {
+			return http.StatusOK
+		}
+		return http.StatusInternalServerError
+	})
+	client := &http.Client{Transport: New(Opts{
+		BreakerThreshold: 1,
+		BreakerCooldown:  time.Millisecond * 10,
+		Rate:             10,
+		Burst:            1,
+	})}
+
+	// Opens the breaker and spends the only token for the next 100ms.
+	if _, err := do(t, client, context.Background(), http.MethodGet, srv.URL); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond * 20)
+
+	// The breaker is due for a trial, but the request gives up waiting for the rate limit.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if _, err := do(t, client, ctx, http.MethodGet, srv.URL); err == nil {
+		t.Fatal("request sent despite the rate limit")
+	}
+
+	healthy.Store(true)
+	time.Sleep(time.Millisecond * 150)
+	resp, err := do(t, client, context.Background(), http.MethodGet, srv.URL)
+	if err != nil {
+		t.Fatalf("trial request error = %v, want it sent", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("trial status = %d, want 200", resp.StatusCode)
+	}
+}
+
+func breakerState(tr *Transport, srv *httptest.Server) int {
+	e := tr.endpoint(strings.TrimPrefix(srv.URL, "http://"))
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.state
+}
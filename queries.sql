@@ -22,13 +22,15 @@ LIMIT 1
 -- $3: recipient_address
 -- $4: transfer_value
 -- $5: contract_address
+-- $6: tenant_id, empty for transfers that do not go to a vault
 INSERT INTO token_transfer(
     tx_id,
     sender_address,
     recipient_address,
     transfer_value,
-    contract_address
-) VALUES($1, $2, $3, $4, $5) ON CONFLICT DO NOTHING
+    contract_address,
+    tenant_id
+) VALUES($1, $2, $3, $4, $5, NULLIF($6, '')) ON CONFLICT DO NOTHING
 
 --name: insert-token
 -- $1: contract_address
@@ -44,6 +46,612 @@ INSERT INTO tokens(
     sink_address
 ) VALUES ($1, $2, $3, $4, $5) ON CONFLICT DO NOTHING
 
---name: get-token-symbol
+--name: get-token
 -- $1: contract_address
-SELECT token_symbol FROM tokens WHERE contract_address = $1 AND removed = false
+SELECT token_symbol, token_decimals FROM tokens WHERE contract_address = $1 AND removed = false
+
+--name: insert-voucher
+-- $1: tx_hash
+-- $2: log_index
+-- $3: sender_address
+-- $4: recipient_address
+-- $5: contract_address
+-- $6: transfer_value
+-- $7: amount
+-- $8: token_symbol
+-- $9: block_number
+-- $10: tenant_id
+-- $11: price
+-- $12: status
+INSERT INTO vouchers(
+    tenant_id,
+    tx_hash,
+    log_index,
+    sender_address,
+    recipient_address,
+    contract_address,
+    transfer_value,
+    amount,
+    token_symbol,
+    block_number,
+    price,
+    status
+) VALUES($10, $1, $2, $3, $4, $5, $6, $7, $8, $9, $11, $12) ON CONFLICT (tx_hash, log_index) DO NOTHING RETURNING id
+
+--name: insert-voucher-item
+-- $1: voucher_id
+-- $2: seq
+-- $3: tier
+-- $4: profile_pk
+-- $5: tier_description
+-- $6: subscription_days
+INSERT INTO voucher_items(
+    voucher_id,
+    seq,
+    tier,
+    profile_pk,
+    tier_description,
+    subscription_days
+) VALUES($1, $2, $3, $4, $5, $6)
+
+--name: fetch-due-vouchers
+-- $1: limit
+-- $2: max_block_number
+-- $3: lease_seconds
+-- Due vouchers are moved to processing so that a crash mid-issue never leads to a second voucher.
+-- Vouchers from blocks above max_block_number do not have enough confirmations yet and stay pending.
+-- Vouchers left in processing for longer than the lease, e.g. by a crash, are taken up again. Items that
+-- already carry a code are not issued a second time.
+UPDATE vouchers SET
+    status = 'processing',
+    attempts = attempts + 1,
+    updated_at = NOW()
+WHERE id IN (
+    SELECT id FROM vouchers
+    WHERE (
+        (status = 'pending' AND next_attempt_at <= NOW())
+        OR (status = 'processing' AND updated_at <= NOW() - make_interval(secs => $3))
+    ) AND block_number <= $2
+    ORDER BY id
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING
+    id,
+    tenant_id,
+    tx_hash,
+    log_index,
+    sender_address,
+    recipient_address,
+    contract_address,
+    transfer_value,
+    amount,
+    token_symbol,
+    block_number,
+    attempts
+
+--name: get-voucher-items
+-- $1: voucher_ids
+SELECT id, voucher_id, tier, profile_pk, tier_description, subscription_days, COALESCE(voucher_code, '') FROM voucher_items WHERE voucher_id = ANY($1) ORDER BY voucher_id, seq
+
+--name: set-voucher-item-issued
+-- $1: id
+-- $2: voucher_code
+UPDATE voucher_items SET voucher_code = $2, issued_at = NOW() WHERE id = $1
+
+--name: set-voucher-issued
+-- $1: id
+UPDATE vouchers SET status = 'issued', last_error = NULL, updated_at = NOW() WHERE id = $1
+
+--name: set-voucher-retry
+-- $1: id
+-- $2: next_attempt_at
+-- $3: last_error
+UPDATE vouchers SET status = 'pending', next_attempt_at = $2, last_error = $3, updated_at = NOW() WHERE id = $1
+
+--name: set-voucher-failed
+-- $1: id
+-- $2: last_error
+UPDATE vouchers SET status = 'failed', last_error = $2, updated_at = NOW() WHERE id = $1
+
+--name: insert-unaccepted-payment
+-- $1: tx_id
+-- $2: log_index
+-- $3: sender_address
+-- $4: recipient_address
+-- $5: contract_address
+-- $6: transfer_value
+INSERT INTO unaccepted_payments(
+    tx_id,
+    log_index,
+    sender_address,
+    recipient_address,
+    contract_address,
+    transfer_value
+) VALUES($1, $2, $3, $4, $5, $6) ON CONFLICT DO NOTHING
+
+--name: insert-credit
+-- $1: sender_address
+-- $2: amount
+-- $3: kind
+-- $4: tx_hash
+-- $5: log_index
+-- $6: tenant_id
+INSERT INTO credits(
+    sender_address,
+    amount,
+    kind,
+    tx_hash,
+    log_index,
+    tenant_id
+) VALUES($1, $2, $3, $4, $5, $6) ON CONFLICT DO NOTHING
+
+--name: payment-recorded
+-- $1: tx_hash
+-- $2: log_index
+-- A payment that already bought a voucher or left a ledger entry must not be processed again on redelivery.
+SELECT
+    EXISTS(SELECT 1 FROM vouchers WHERE tx_hash = $1 AND log_index = $2)
+    OR EXISTS(SELECT 1 FROM unmatched_payments WHERE tx_hash = $1 AND log_index = $2)
+    OR EXISTS(SELECT 1 FROM credits WHERE tx_hash = $1 AND log_index = $2)
+    OR EXISTS(SELECT 1 FROM partial_payments WHERE tx_hash = $1 AND log_index = $2)
+
+--name: get-credit-balance
+-- $1: tenant_id
+-- $2: sender_address
+SELECT COALESCE(SUM(amount), 0)::TEXT FROM credits WHERE tenant_id = $1 AND sender_address = $2
+
+--name: get-credits
+-- $1: tenant_id
+-- $2: sender_address
+SELECT amount::TEXT, kind, tx_hash, log_index, created_at FROM credits WHERE tenant_id = $1 AND sender_address = $2 ORDER BY id DESC
+
+--name: insert-partial-payment
+-- $1: sender_address
+-- $2: amount
+-- $3: tx_hash
+-- $4: log_index
+-- $5: expires_at
+-- $6: tenant_id
+INSERT INTO partial_payments(
+    sender_address,
+    amount,
+    tx_hash,
+    log_index,
+    expires_at,
+    tenant_id
+) VALUES($1, $2, $3, $4, $5, $6) ON CONFLICT DO NOTHING
+
+--name: get-partial-balance
+-- $1: tenant_id
+-- $2: sender_address
+-- $3: at
+SELECT COALESCE(SUM(amount), 0)::TEXT FROM partial_payments WHERE tenant_id = $1 AND sender_address = $2 AND settled_at IS NULL AND expires_at > $3
+
+--name: settle-partial-payments
+-- $1: tenant_id
+-- $2: sender_address
+-- $3: at
+-- $4: settled_tx_hash
+-- $5: settled_log_index
+UPDATE partial_payments SET settled_at = NOW(), settled_tx_hash = $4, settled_log_index = $5
+WHERE tenant_id = $1 AND sender_address = $2 AND settled_at IS NULL AND expires_at > $3
+
+--name: unsettle-partial-payments
+-- $1: settled_tx_hash
+-- $2: settled_log_index
+UPDATE partial_payments SET settled_at = NULL, settled_tx_hash = NULL, settled_log_index = NULL
+WHERE settled_tx_hash = $1 AND settled_log_index = $2
+
+--name: reverse-purchase-credits
+-- $1: tx_hash
+-- $2: log_index
+-- Books the negated sum of a purchase's credit entries, leaving the balance as it was before the purchase
+INSERT INTO credits(
+    sender_address,
+    amount,
+    kind,
+    tx_hash,
+    log_index,
+    tenant_id
+) SELECT sender_address, -SUM(amount), 'reversal', tx_hash, log_index, tenant_id
+FROM credits
+WHERE tx_hash = $1 AND log_index = $2 AND kind IN ('purchase', 'overpayment')
+GROUP BY sender_address, tx_hash, log_index, tenant_id
+HAVING SUM(amount) <> 0
+ON CONFLICT DO NOTHING
+
+--name: set-voucher-rejected
+-- $1: id
+-- $2: last_error
+UPDATE vouchers SET status = 'rejected', last_error = $2, updated_at = NOW() WHERE id = $1
+
+--name: extend-subscription
+-- $1: tenant_id
+-- $2: vault_address
+-- $3: sender_address
+-- $4: tier
+-- $5: tier_description
+-- $6: days
+-- Paying again before expiry extends the current end date, otherwise a new period starts now
+INSERT INTO subscriptions(
+    tenant_id,
+    vault_address,
+    sender_address,
+    tier,
+    tier_description,
+    starts_at,
+    ends_at
+) VALUES($1, $2, $3, $4, $5, NOW(), NOW() + make_interval(days => $6))
+ON CONFLICT (tenant_id, sender_address, tier) DO UPDATE SET
+    vault_address = EXCLUDED.vault_address,
+    tier_description = EXCLUDED.tier_description,
+    starts_at = CASE WHEN subscriptions.ends_at > NOW() THEN subscriptions.starts_at ELSE NOW() END,
+    ends_at = GREATEST(subscriptions.ends_at, NOW()) + make_interval(days => $6),
+    reminded_at = NULL,
+    updated_at = NOW()
+RETURNING ends_at
+
+--name: get-expiring-subscriptions
+-- $1: before
+SELECT id, tenant_id, vault_address, sender_address, tier, tier_description, starts_at, ends_at FROM subscriptions
+WHERE reminded_at IS NULL AND ends_at > NOW() AND ends_at <= $1
+ORDER BY ends_at
+
+--name: set-subscription-reminded
+-- $1: id
+UPDATE subscriptions SET reminded_at = NOW() WHERE id = $1
+
+--name: get-sender-usage
+-- $1: tenant_id
+-- $2: sender_address
+-- $3: since
+-- $4: until
+-- Windows are in block time, so that a replayed transfer is checked against the purchases made around it
+WITH recent AS (
+    SELECT vouchers.id, vouchers.price FROM vouchers
+    INNER JOIN tx ON tx.tx_hash = vouchers.tx_hash
+    WHERE vouchers.tenant_id = $1 AND vouchers.sender_address = $2 AND tx.date_block >= $3 AND tx.date_block <= $4 AND vouchers.status <> 'rejected'
+)
+SELECT
+    (SELECT COUNT(*) FROM voucher_items WHERE voucher_id IN (SELECT id FROM recent)),
+    (SELECT COALESCE(SUM(price), 0)::TEXT FROM recent)
+
+--name: get-tenant-usage
+-- $1: tenant_id
+-- $2: since
+-- $3: until
+SELECT COUNT(voucher_items.id) FROM vouchers
+INNER JOIN voucher_items ON voucher_items.voucher_id = vouchers.id
+INNER JOIN tx ON tx.tx_hash = vouchers.tx_hash
+WHERE vouchers.tenant_id = $1 AND tx.date_block >= $2 AND tx.date_block <= $3 AND vouchers.status <> 'rejected'
+
+--name: insert-review
+-- $1: voucher_id
+-- $2: tenant_id
+-- $3: sender_address
+-- $4: rule
+-- $5: reason
+INSERT INTO review_queue(
+    voucher_id,
+    tenant_id,
+    sender_address,
+    rule,
+    reason
+) VALUES($1, $2, $3, $4, $5) ON CONFLICT DO NOTHING
+
+--name: list-reviews
+-- $1: tenant_id
+-- $2: status
+SELECT
+    review_queue.id,
+    review_queue.voucher_id,
+    review_queue.tenant_id,
+    review_queue.sender_address,
+    review_queue.rule,
+    review_queue.reason,
+    review_queue.status,
+    review_queue.resolution_note,
+    vouchers.tx_hash,
+    vouchers.log_index,
+    vouchers.recipient_address,
+    vouchers.contract_address,
+    vouchers.transfer_value::TEXT,
+    vouchers.amount,
+    vouchers.token_symbol,
+    vouchers.price::TEXT,
+    review_queue.created_at,
+    review_queue.resolved_at
+FROM review_queue
+INNER JOIN vouchers ON vouchers.id = review_queue.voucher_id
+WHERE review_queue.tenant_id = $1 AND review_queue.status = $2
+ORDER BY review_queue.id
+
+--name: get-review
+-- $1: id
+SELECT
+    review_queue.id,
+    review_queue.voucher_id,
+    review_queue.tenant_id,
+    review_queue.sender_address,
+    review_queue.rule,
+    review_queue.reason,
+    review_queue.status,
+    review_queue.resolution_note,
+    vouchers.tx_hash,
+    vouchers.log_index,
+    vouchers.recipient_address,
+    vouchers.contract_address,
+    vouchers.transfer_value::TEXT,
+    vouchers.amount,
+    vouchers.token_symbol,
+    vouchers.price::TEXT,
+    review_queue.created_at,
+    review_queue.resolved_at
+FROM review_queue
+INNER JOIN vouchers ON vouchers.id = review_queue.voucher_id
+WHERE review_queue.id = $1
+
+--name: resolve-review
+-- $1: id
+-- $2: status
+-- $3: resolution_note
+UPDATE review_queue SET status = $2, resolution_note = $3, resolved_at = NOW() WHERE id = $1 AND status = 'open'
+RETURNING voucher_id
+
+--name: release-held-voucher
+-- $1: id
+UPDATE vouchers SET status = 'pending', next_attempt_at = NOW(), updated_at = NOW() WHERE id = $1 AND status = 'held'
+
+--name: reject-held-voucher
+-- $1: id
+-- $2: last_error
+UPDATE vouchers SET status = 'rejected', last_error = $2, updated_at = NOW() WHERE id = $1 AND status = 'held'
+RETURNING tx_hash, log_index
+
+--name: get-address
+-- $1: tenant_id
+-- $2: address
+SELECT tenant_id, address, allowed, blocked, member, pricing_group, note, updated_at FROM address_registry
+WHERE tenant_id = $1 AND address = $2
+
+--name: list-addresses
+-- $1: tenant_id
+SELECT tenant_id, address, allowed, blocked, member, pricing_group, note, updated_at FROM address_registry
+WHERE tenant_id = $1 ORDER BY address
+
+--name: upsert-address
+-- $1: tenant_id
+-- $2: address
+-- $3: allowed
+-- $4: blocked
+-- $5: member
+-- $6: pricing_group
+-- $7: note
+INSERT INTO address_registry(
+    tenant_id,
+    address,
+    allowed,
+    blocked,
+    member,
+    pricing_group,
+    note
+) VALUES($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT (tenant_id, address) DO UPDATE SET
+    allowed = EXCLUDED.allowed,
+    blocked = EXCLUDED.blocked,
+    member = EXCLUDED.member,
+    pricing_group = EXCLUDED.pricing_group,
+    note = EXCLUDED.note,
+    updated_at = NOW()
+RETURNING updated_at
+
+--name: delete-address
+-- $1: tenant_id
+-- $2: address
+DELETE FROM address_registry WHERE tenant_id = $1 AND address = $2
+
+--name: insert-unmatched-payment
+-- $1: tenant_id
+-- $2: tx_hash
+-- $3: log_index
+-- $4: sender_address
+-- $5: recipient_address
+-- $6: contract_address
+-- $7: transfer_value
+-- $8: token_amount
+-- $9: token_symbol
+-- $10: amount
+-- $11: block_number
+-- $12: reason
+INSERT INTO unmatched_payments(
+    tenant_id,
+    tx_hash,
+    log_index,
+    sender_address,
+    recipient_address,
+    contract_address,
+    transfer_value,
+    token_amount,
+    token_symbol,
+    amount,
+    block_number,
+    reason
+) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) ON CONFLICT DO NOTHING
+
+--name: list-unmatched-payments
+-- $1: tenant_id
+-- $2: status
+SELECT id, tenant_id, tx_hash, log_index, sender_address, recipient_address, contract_address, transfer_value::TEXT, token_amount, token_symbol, amount::TEXT, block_number, reason, status, resolution_note, created_at, resolved_at FROM unmatched_payments
+WHERE tenant_id = $1 AND status = $2 ORDER BY id
+
+--name: get-unmatched-payment
+-- $1: id
+SELECT id, tenant_id, tx_hash, log_index, sender_address, recipient_address, contract_address, transfer_value::TEXT, token_amount, token_symbol, amount::TEXT, block_number, reason, status, resolution_note, created_at, resolved_at FROM unmatched_payments
+WHERE id = $1
+
+--name: resolve-unmatched-payment
+-- $1: id
+-- $2: status
+-- $3: resolution_note
+UPDATE unmatched_payments SET status = $2, resolution_note = $3, resolved_at = NOW() WHERE id = $1 AND status = 'open'
+
+--name: insert-refund
+-- $1: tenant_id
+-- $2: source
+-- $3: tx_hash
+-- $4: log_index
+-- $5: vault_address
+-- $6: sender_address
+-- $7: contract_address
+-- $8: value
+-- A payment with any issued voucher is never refunded, it is left for manual review
+INSERT INTO refunds(
+    tenant_id,
+    source,
+    tx_hash,
+    log_index,
+    vault_address,
+    sender_address,
+    contract_address,
+    value
+) SELECT $1, $2, $3, $4, $5, $6, $7, $8
+WHERE NOT EXISTS (
+    SELECT 1 FROM voucher_items
+    INNER JOIN vouchers ON vouchers.id = voucher_items.voucher_id
+    WHERE vouchers.tx_hash = $3 AND vouchers.log_index = $4 AND voucher_items.voucher_code IS NOT NULL
+) ON CONFLICT DO NOTHING
+
+--name: fetch-refunds
+-- $1: status
+-- $2: limit
+SELECT id, tenant_id, source, tx_hash, log_index, vault_address, sender_address, contract_address, value::TEXT, status, COALESCE(refund_tx_hash, ''), raw_tx, attempts FROM refunds
+WHERE status = $1 ORDER BY id LIMIT $2
+
+--name: set-refund-sent
+-- $1: id
+-- $2: refund_tx_hash
+-- $3: raw_tx
+UPDATE refunds SET status = 'sent', refund_tx_hash = $2, raw_tx = $3, updated_at = NOW() WHERE id = $1
+
+--name: set-refund-retry
+-- $1: id
+-- $2: last_error
+-- Keeps the status, a sent refund is only ever broadcast again and never signed anew
+UPDATE refunds SET attempts = attempts + 1, last_error = $2, updated_at = NOW() WHERE id = $1
+
+--name: set-refund-confirmed
+-- $1: id
+UPDATE refunds SET status = 'confirmed', updated_at = NOW() WHERE id = $1
+
+--name: set-refund-failed
+-- $1: id
+-- $2: last_error
+UPDATE refunds SET status = 'failed', last_error = $2, updated_at = NOW() WHERE id = $1
+
+--name: expire-orders
+UPDATE orders SET status = 'expired' WHERE status = 'open' AND expires_at < NOW()
+
+--name: insert-order
+-- $1: id
+-- $2: tenant_id
+-- $3: vault_address
+-- $4: tier
+-- $5: tier_description
+-- $6: contract_address
+-- $7: token_symbol
+-- $8: token_amount
+-- $9: transfer_value
+-- $10: expires_at
+INSERT INTO orders(
+    id,
+    tenant_id,
+    vault_address,
+    tier,
+    tier_description,
+    contract_address,
+    token_symbol,
+    token_amount,
+    transfer_value,
+    expires_at
+) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING created_at
+
+--name: get-order
+-- $1: id
+-- An open order past its expiry is reported as expired before expire-orders has run
+SELECT
+    orders.id,
+    orders.tenant_id,
+    orders.vault_address,
+    orders.tier,
+    orders.tier_description,
+    orders.contract_address,
+    orders.token_symbol,
+    orders.token_amount,
+    orders.transfer_value::TEXT,
+    CASE WHEN orders.status = 'open' AND orders.expires_at < NOW() THEN 'expired' ELSE orders.status END,
+    COALESCE(orders.tx_hash, ''),
+    COALESCE(vouchers.status, ''),
+    orders.expires_at,
+    orders.created_at
+FROM orders
+LEFT JOIN vouchers ON vouchers.tx_hash = orders.tx_hash AND vouchers.log_index = orders.log_index
+WHERE orders.id = $1
+
+--name: count-open-orders
+-- $1: vault_address
+SELECT COUNT(*) FROM orders WHERE vault_address = $1 AND status = 'open' AND expires_at >= NOW()
+
+--name: get-open-order
+-- $1: vault_address
+-- $2: contract_address
+-- $3: transfer_value
+-- $4: at
+SELECT id, tier FROM orders
+WHERE vault_address = $1 AND contract_address = $2 AND transfer_value = $3 AND status = 'open' AND expires_at >= $4
+
+--name: set-order-paid
+-- $1: id
+-- $2: tx_hash
+-- $3: log_index
+UPDATE orders SET status = 'paid', tx_hash = $2, log_index = $3, paid_at = NOW() WHERE id = $1 AND status = 'open'
+
+--name: insert-pool-code
+-- $1: vault_address
+-- $2: profile_pk
+-- $3: code
+INSERT INTO voucher_pool(vault_address, profile_pk, code) VALUES($1, $2, $3) ON CONFLICT DO NOTHING
+
+--name: get-pool-claim
+-- $1: voucher_item_id
+SELECT code FROM voucher_pool WHERE voucher_item_id = $1
+
+--name: claim-pool-code
+-- $1: vault_address
+-- $2: profile_pk
+-- $3: voucher_item_id
+UPDATE voucher_pool SET voucher_item_id = $3, claimed_at = NOW()
+WHERE id = (
+    SELECT id FROM voucher_pool
+    WHERE vault_address = $1 AND profile_pk = $2 AND voucher_item_id IS NULL
+    ORDER BY id
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING code
+
+--name: count-pool-codes
+-- $1: vault_address
+-- $2: profile_pk
+SELECT COUNT(*) FROM voucher_pool WHERE vault_address = $1 AND profile_pk = $2 AND voucher_item_id IS NULL
+
+--name: list-pool-stock
+-- $1: vault_address
+SELECT
+    profile_pk,
+    COUNT(*) FILTER (WHERE voucher_item_id IS NULL),
+    COUNT(*) FILTER (WHERE voucher_item_id IS NOT NULL)
+FROM voucher_pool
+WHERE vault_address = $1
+GROUP BY profile_pk
+ORDER BY profile_pk
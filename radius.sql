@@ -0,0 +1,20 @@
+--name: insert-password
+-- $1: username
+-- The voucher code is both the username and the password
+INSERT INTO radcheck (username, attribute, op, value) VALUES ($1, 'Cleartext-Password', ':=', $1)
+
+--name: insert-group
+-- $1: username
+-- $2: groupname
+INSERT INTO radusergroup (username, groupname, priority) VALUES ($1, $2, 1)
+
+--name: insert-reject
+-- $1: username
+INSERT INTO radcheck (username, attribute, op, value) VALUES ($1, 'Auth-Type', ':=', 'Reject')
+
+--name: get-status
+-- $1: username
+SELECT
+    EXISTS(SELECT 1 FROM radcheck WHERE username = $1 AND attribute = 'Cleartext-Password'),
+    EXISTS(SELECT 1 FROM radcheck WHERE username = $1 AND attribute = 'Auth-Type' AND value = 'Reject'),
+    EXISTS(SELECT 1 FROM radacct WHERE username = $1)